@@ -2,32 +2,85 @@ package cflag
 
 import (
 	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	flag "github.com/spf13/pflag"
 	"golang.org/x/term"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 	"slices"
 	"strings"
 )
 
 type UsageFunc func(command *Command)
 type CommandCallback func(command *Command, flags *flag.FlagSet) error
+type ContextCallback func(ctx context.Context, command *Command, flags *flag.FlagSet) error
 
 // A Command represents a (sub)command with a set of defined flags.
 type Command struct {
 	name        string
 	usage       string
 	description string
+	aliases     []string
 	active      bool
 	hidden      bool
 	deprecated  bool
 	recurseArgs bool
+	noExit      bool
+	prefixMatch bool
+	noSuggest   bool
+	suggestDist int
 	flags       *flag.FlagSet
+	persistent  *flag.FlagSet
+	parent      *Command
 	commands    []*Command
+	args        []*Arg
+	argRange    *argRange
+	argValues   []string
+	argsAtDash  int
 	output      io.Writer
 	usageFunc   UsageFunc
-	callback    CommandCallback
+	callback    ContextCallback
+	flagComps   map[string]CompletionFunc
+	argComps    map[string]CompletionFunc
+	envPrefix   string
+	envVars     map[string]string
+	sources     map[string]FlagSource
+	configName  string
+	configFlag  *flag.Flag
+	configFile  string
+	ctx         context.Context
+	sigCancel   bool
+	preRun      CommandCallback
+	postRun     CommandCallback
+	pPreRun     CommandCallback
+	pPostRun    CommandCallback
+	flagGroups  []*flagGroup
+	validators  map[string][]func(string) error
+	enums       map[string][]string
+	respFiles   bool
+	multiCall   bool
+	plugins     string
+	pluginList  []Plugin
+	flagStates  map[*flag.Flag]reflect.Value
+}
+
+// An Arg describes a positional argument accepted by a command.
+type Arg struct {
+	Name     string
+	Usage    string
+	Optional bool
+	Variadic bool
+}
+
+// argRange holds the minimum and maximum number of positional arguments
+// accepted by a command. A negative max means no upper limit.
+type argRange struct {
+	min, max int
 }
 
 // The gap between the start of the line and the command name.
@@ -36,6 +89,9 @@ const commandGapLen = 2
 // The minimum gap between the command name and the command usage.
 const commandUsageGapLen = 3
 
+// The default maximum edit distance for suggesting a command name.
+const defaultSuggestionDistance = 2
+
 // Holds the global command register,
 // i.e. top-level flags and commands defined for the application.
 var command Command
@@ -48,13 +104,16 @@ func (c *Command) AddCommand(command *Command) error {
 		return fmt.Errorf("invalid parameters")
 	}
 
-	// Check if a command with the same name is already defined.
-	if slices.ContainsFunc(c.commands, func(cmd *Command) bool {
-		return cmd.name == command.name
-	}) {
-		return fmt.Errorf("command with name '%s' already exists", command.name)
+	// Check if a command with the same name or alias is already defined.
+	for _, name := range command.names() {
+		if slices.ContainsFunc(c.commands, func(cmd *Command) bool {
+			return cmd.hasName(name)
+		}) {
+			return fmt.Errorf("command with name '%s' already exists", name)
+		}
 	}
 
+	command.parent = c
 	c.commands = append(c.commands, command)
 	return nil
 }
@@ -70,6 +129,57 @@ func (c *Command) Cmd(name string, usage string, flags *flag.FlagSet) (*Command,
 	return cmd, nil
 }
 
+// AddArg declares a required positional argument.
+// Arguments are assigned in the order they are declared.
+// A required argument cannot follow an optional or variadic argument.
+func (c *Command) AddArg(name string, usage string) error {
+	return c.addArg(&Arg{Name: name, Usage: usage})
+}
+
+// AddOptionalArg declares an optional positional argument.
+// An optional argument cannot follow a variadic argument.
+func (c *Command) AddOptionalArg(name string, usage string) error {
+	return c.addArg(&Arg{Name: name, Usage: usage, Optional: true})
+}
+
+// AddVariadicArg declares a positional argument which receives all
+// remaining arguments. It must be the last declared argument.
+// By default, the variadic argument is optional. Use SetArgRange to
+// require a minimum number of arguments.
+func (c *Command) AddVariadicArg(name string, usage string) error {
+	return c.addArg(&Arg{Name: name, Usage: usage, Optional: true, Variadic: true})
+}
+
+// SetArgRange overrides the number of positional arguments accepted
+// by the command, which is otherwise derived from the declared arguments.
+// A negative max means there is no upper limit.
+func (c *Command) SetArgRange(min, max int) *Command {
+	c.argRange = &argRange{min: min, max: max}
+	return c
+}
+
+// SetAliases defines alternative names for the command.
+// When the command has already been added to a parent command and an alias
+// collides with the name or alias of another subcommand of the parent command,
+// the operation is cancelled and an error is returned.
+func (c *Command) SetAliases(aliases ...string) error {
+	for _, alias := range aliases {
+		if len(alias) == 0 {
+			return fmt.Errorf("invalid parameters")
+		}
+
+		// Check if another command with the same name or alias is already defined.
+		if c.parent != nil && slices.ContainsFunc(c.parent.commands, func(cmd *Command) bool {
+			return cmd != c && cmd.hasName(alias)
+		}) {
+			return fmt.Errorf("command with name '%s' already exists", alias)
+		}
+	}
+
+	c.aliases = aliases
+	return nil
+}
+
 // SetDescription defines a long description that is
 // displayed on the generated help page. See CommandUsages.
 func (c *Command) SetDescription(description string) *Command {
@@ -91,10 +201,58 @@ func (c *Command) SetUsageFunc(usageFunc UsageFunc) *Command {
 // The last active command (with or without a callback defined)
 // is passed to the callback.
 func (c *Command) SetCallback(callback CommandCallback) *Command {
+	if callback == nil {
+		c.callback = nil
+		return c
+	}
+	c.callback = func(ctx context.Context, command *Command, flags *flag.FlagSet) error {
+		return callback(command, flags)
+	}
+	return c
+}
+
+// SetContextCallback sets a callback like SetCallback, which receives
+// the context passed to ExecuteContext. It replaces a callback set via SetCallback.
+func (c *Command) SetContextCallback(callback ContextCallback) *Command {
 	c.callback = callback
 	return c
 }
 
+// SetPreRun sets a hook which is executed before the callback
+// when the command is the last active command.
+// The last active command is passed to the hook.
+func (c *Command) SetPreRun(hook CommandCallback) *Command {
+	c.preRun = hook
+	return c
+}
+
+// SetPostRun sets a hook which is executed after the callback
+// when the command is the last active command, even if the callback
+// returned an error. The last active command is passed to the hook.
+func (c *Command) SetPostRun(hook CommandCallback) *Command {
+	c.postRun = hook
+	return c
+}
+
+// SetPersistentPreRun sets a hook which is executed before the callback
+// when the command is active, i.e. also when one of its subcommands is executed.
+// The hooks of the active commands are executed top-down, followed by the
+// hook set via SetPreRun. The last active command is passed to the hook.
+// When a hook returns an error, the remaining hooks and the callback are skipped.
+func (c *Command) SetPersistentPreRun(hook CommandCallback) *Command {
+	c.pPreRun = hook
+	return c
+}
+
+// SetPersistentPostRun sets a hook which is executed after the callback
+// when the command is active, even if the callback returned an error.
+// The hook set via SetPostRun is executed first, followed by the hooks
+// of the active commands bottom-up. The last active command is passed to the hook.
+func (c *Command) SetPersistentPostRun(hook CommandCallback) *Command {
+	c.pPostRun = hook
+	return c
+}
+
 // SetOutput sets the destination for usage and error messages.
 // If output is nil, os.Stderr is used.
 func (c *Command) SetOutput(output io.Writer) *Command {
@@ -117,6 +275,59 @@ func (c *Command) MarkDeprecated() *Command {
 	return c
 }
 
+// SetPersistentFlags defines a set of flags which is accepted by the command
+// and all its subcommands. During parsing, the persistent flags are added to
+// the flag set of every active command below this command, unless a flag
+// with the same name or shorthand is already defined there.
+func (c *Command) SetPersistentFlags(flags *flag.FlagSet) *Command {
+	c.persistent = flags
+
+	// Keep the built-in config flag. See Command.SetConfigFile.
+	if c.configFlag != nil {
+		if c.persistent == nil {
+			c.persistent = NewFlagSet("", flag.ContinueOnError)
+		}
+		mergeFlag(c.persistent, c.configFlag)
+	}
+	return c
+}
+
+// SetPrefixMatching enables matching subcommands of the command and
+// all its subcommands by a unique prefix of their names or aliases,
+// e.g. "inst" resolves to "install" unless another command starts with "inst".
+func (c *Command) SetPrefixMatching() *Command {
+	c.prefixMatch = true
+	return c
+}
+
+// SetMultiCall enables the multi-call mode for the top-level command c.
+// When the base name of the first argument passed to Parse, i.e. the name of
+// the executable, equals the name or an alias of a subcommand, the command
+// line is parsed as if the subcommand name was supplied as second argument.
+// This allows a single binary to be installed under several names using
+// symbolic links, e.g. "app-foo -> app" with a subcommand aliased "app-foo".
+func (c *Command) SetMultiCall() *Command {
+	c.multiCall = true
+	return c
+}
+
+// SetSuggestionDistance sets the maximum edit distance between an unknown
+// command name and the names of the subcommands to suggest them.
+// The default distance is 2.
+func (c *Command) SetSuggestionDistance(distance int) *Command {
+	c.suggestDist = distance
+	return c
+}
+
+// DisableSuggestions disables detecting unknown command names and suggesting
+// subcommands of the command. Positional arguments which do not name a
+// subcommand are then accepted like by commands without subcommands.
+// Alternatively, use SetArgRange(0, -1) to accept any positional arguments.
+func (c *Command) DisableSuggestions() *Command {
+	c.noSuggest = true
+	return c
+}
+
 // SetRecurseArguments enables recursive parsing of arguments
 // using the parent commands of this command.
 func (c *Command) SetRecurseArguments() *Command {
@@ -124,6 +335,16 @@ func (c *Command) SetRecurseArguments() *Command {
 	return c
 }
 
+// SetNoExit enables the no-exit mode for the command and its subcommands.
+// In no-exit mode, Parse returns ErrHelpRequested after printing the help page
+// instead of calling os.Exit, and flag sets are parsed with flag.ContinueOnError
+// regardless of the error handling they were created with, so that errors
+// are returned as *FlagParseError.
+func (c *Command) SetNoExit() *Command {
+	c.noExit = true
+	return c
+}
+
 // IsActive reports whether the command is active,
 // i.e. it was supplied to the command line when calling Parse.
 func (c *Command) IsActive() bool {
@@ -148,6 +369,12 @@ func (c *Command) GetName() string {
 	return c.name
 }
 
+// GetAliases returns the alternative names of the command.
+// See Command.SetAliases.
+func (c *Command) GetAliases() []string {
+	return c.aliases
+}
+
 // GetUsage returns the command usage.
 func (c *Command) GetUsage() string {
 	return c.usage
@@ -159,7 +386,140 @@ func (c *Command) GetDescription() string {
 	return c.description
 }
 
-// Lookup searches for a registered subcommand by its name.
+// GetFlags returns the flag set of the command.
+// After parsing, it includes the persistent flags inherited from parent commands.
+func (c *Command) GetFlags() *flag.FlagSet {
+	return c.flags
+}
+
+// GetPersistentFlags returns the persistent flag set of the command if set.
+// See Command.SetPersistentFlags.
+func (c *Command) GetPersistentFlags() *flag.FlagSet {
+	return c.persistent
+}
+
+// LocalFlags returns a flag set containing the flags and persistent flags
+// defined for this command, excluding flags inherited from parent commands.
+func (c *Command) LocalFlags() *flag.FlagSet {
+	local := NewFlagSet("", flag.ContinueOnError)
+
+	// Add flags which are not inherited from parent commands.
+	if c.flags != nil {
+		local.SortFlags = c.flags.SortFlags
+		inherited := c.InheritedFlags()
+		c.flags.VisitAll(func(f *flag.Flag) {
+			if inherited.Lookup(f.Name) != f {
+				local.AddFlag(f)
+			}
+		})
+	}
+	mergeFlags(local, c.persistent)
+
+	return local
+}
+
+// InheritedFlags returns a flag set containing the persistent flags
+// defined for all parent commands, excluding flags overridden by this command.
+func (c *Command) InheritedFlags() *flag.FlagSet {
+	inherited := NewFlagSet("", flag.ContinueOnError)
+	sortFlagsSet := false
+
+	// isOverridden reports whether a flag with the same name or shorthand
+	// is defined by this command.
+	isOverridden := func(f *flag.Flag) bool {
+		for _, flags := range []*flag.FlagSet{c.flags, c.persistent} {
+			if flags == nil {
+				continue
+			}
+			if local := flags.Lookup(f.Name); local != nil && local != f {
+				return true
+			}
+			if len(f.Shorthand) > 0 {
+				if local := flags.ShorthandLookup(f.Shorthand); local != nil && local != f {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// Add persistent flags, with the nearest parent command taking precedence.
+	for cmd := c.parent; cmd != nil; cmd = cmd.parent {
+		if cmd.persistent == nil {
+			continue
+		}
+		if !sortFlagsSet {
+			inherited.SortFlags = cmd.persistent.SortFlags
+			sortFlagsSet = true
+		}
+		cmd.persistent.VisitAll(func(f *flag.Flag) {
+			if !isOverridden(f) {
+				mergeFlag(inherited, f)
+			}
+		})
+	}
+
+	return inherited
+}
+
+// GetParent returns the parent command or nil for a top-level command.
+func (c *Command) GetParent() *Command {
+	return c.parent
+}
+
+// GetCommandPath returns the names of the command and all its parent
+// commands separated by spaces, e.g. "app foo bar".
+// An empty top-level command name is replaced by the application name.
+func (c *Command) GetCommandPath() string {
+	name := c.name
+	if c.parent == nil && len(name) == 0 {
+		name = filepath.Base(os.Args[0])
+	}
+	if c.parent == nil {
+		return name
+	}
+	return c.parent.GetCommandPath() + " " + name
+}
+
+// GetArgs returns all positional arguments supplied to the command.
+func (c *Command) GetArgs() []string {
+	return c.argValues
+}
+
+// GetArgValues returns the values supplied for the declared positional
+// argument name. A variadic argument may receive multiple values.
+// If no value was supplied, nil is returned.
+func (c *Command) GetArgValues(name string) []string {
+	iValue := 0
+	for _, arg := range c.args {
+		if iValue >= len(c.argValues) {
+			return nil
+		}
+		if arg.Variadic {
+			if arg.Name == name {
+				return c.argValues[iValue:]
+			}
+			return nil
+		}
+		if arg.Name == name {
+			return c.argValues[iValue : iValue+1]
+		}
+		iValue++
+	}
+	return nil
+}
+
+// GetArg returns the value supplied for the declared positional
+// argument name, or an empty string if no value was supplied.
+// For a variadic argument, the first value is returned.
+func (c *Command) GetArg(name string) string {
+	if values := c.GetArgValues(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Lookup searches for a registered subcommand by its name or alias.
 // If no matching command is found, nil is returned.
 func (c *Command) Lookup(name string) *Command {
 	if len(name) == 0 {
@@ -168,7 +528,7 @@ func (c *Command) Lookup(name string) *Command {
 
 	// Find command with matching name.
 	if iCmd := slices.IndexFunc(c.commands, func(cmd *Command) bool {
-		return cmd.name == name
+		return cmd.hasName(name)
 	}); iCmd >= 0 {
 		return c.commands[iCmd]
 	}
@@ -176,6 +536,34 @@ func (c *Command) Lookup(name string) *Command {
 	return nil
 }
 
+// SuggestionsFor returns the names of visible subcommands which are similar
+// to name, i.e. which start with name or whose name or one of its aliases
+// is within the suggestion distance of name. See Command.SetSuggestionDistance.
+func (c *Command) SuggestionsFor(name string) []string {
+	if c.noSuggest || len(name) == 0 {
+		return nil
+	}
+
+	distance := c.suggestDist
+	if distance <= 0 {
+		distance = defaultSuggestionDistance
+	}
+
+	var suggestions []string
+	for _, cmd := range c.commands {
+		if cmd.hidden {
+			continue
+		}
+		if slices.ContainsFunc(cmd.names(), func(cmdName string) bool {
+			return strings.HasPrefix(cmdName, name) || levenshtein(cmdName, name) <= distance
+		}) {
+			suggestions = append(suggestions, cmd.name)
+		}
+	}
+
+	return suggestions
+}
+
 // Active searches for a registered subcommand by its name
 // and reports its activation state. See IsActive.
 func (c *Command) Active(name string) bool {
@@ -194,37 +582,18 @@ func (c *Command) CommandUsagesWrapped(cols int) string {
 		return ""
 	}
 
-	buf := new(bytes.Buffer)
-
 	// Filter visible commands.
 	visibleCommands := filterSlice(c.commands, func(c *Command) bool {
 		return !c.hidden
 	})
 
-	// Find maximum name length to calculate gap width.
-	maxNameLen := 0
+	// Create rows containing command names and usages.
+	var rows [][2]string
 	for _, cmd := range visibleCommands {
-		nameLen := len(cmd.name)
-		if nameLen > maxNameLen {
-			maxNameLen = nameLen
-		}
+		rows = append(rows, [2]string{strings.Join(cmd.names(), ", "), cmd.usage})
 	}
 
-	// Get the full gap until usages are printed for wrapping.
-	fullUsageGapLen := commandGapLen + maxNameLen + commandUsageGapLen
-
-	// Create line containing command name and usage.
-	for _, cmd := range visibleCommands {
-		nameLen := len(cmd.name)
-		gap := strings.Repeat(" ", commandGapLen)
-		usageGapLen := maxNameLen - nameLen + commandUsageGapLen
-		usageGap := strings.Repeat(" ", usageGapLen)
-		cmdUsage := wrap(fullUsageGapLen, cols, cmd.usage)
-		_, _ = fmt.Fprintln(buf, gap+cmd.name+usageGap+cmdUsage)
-	}
-
-	// Return usages string.
-	return buf.String()
+	return usageTable(rows, cols)
 }
 
 // CommandUsages returns a string containing the usage information
@@ -233,14 +602,56 @@ func (c *Command) CommandUsages() string {
 	return c.CommandUsagesWrapped(0)
 }
 
+// ArgUsagesWrapped returns a string containing the usage information
+// for all positional arguments declared for this command.
+// Wrapped to cols columns (0 for no wrapping).
+func (c *Command) ArgUsagesWrapped(cols int) string {
+	if len(c.args) == 0 {
+		return ""
+	}
+
+	// Create rows containing argument names and usages.
+	var rows [][2]string
+	for _, arg := range c.args {
+		rows = append(rows, [2]string{arg.Name, arg.Usage})
+	}
+
+	return usageTable(rows, cols)
+}
+
+// ArgUsages returns a string containing the usage information
+// for all positional arguments declared for this command.
+func (c *Command) ArgUsages() string {
+	return c.ArgUsagesWrapped(0)
+}
+
+// Synopsis returns a line describing how to invoke the command,
+// including its declared positional arguments, e.g. "app foo <src> [dst...]".
+func (c *Command) Synopsis() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(c.GetCommandPath())
+
+	min, _ := c.getArgRange()
+	for i, arg := range c.args {
+		name := arg.Name
+		if arg.Variadic {
+			name += "..."
+		}
+		if arg.Optional && i >= min {
+			buf.WriteString(" [" + name + "]")
+		} else {
+			buf.WriteString(" <" + name + ">")
+		}
+	}
+
+	return buf.String()
+}
+
 // FlagUsagesWrapped returns a string containing the usage information
 // for all flags defined for this command.
 // Wrapped to cols columns (0 for no wrapping).
 func (c *Command) FlagUsagesWrapped(cols int) string {
-	if c.flags == nil {
-		return ""
-	}
-	return c.flags.FlagUsagesWrapped(cols)
+	return c.usageFlags(c.LocalFlags()).FlagUsagesWrapped(cols)
 }
 
 // FlagUsages returns a string containing the usage information for all flags
@@ -249,6 +660,19 @@ func (c *Command) FlagUsages() string {
 	return c.FlagUsagesWrapped(0)
 }
 
+// InheritedFlagUsagesWrapped returns a string containing the usage information
+// for all persistent flags inherited from parent commands.
+// Wrapped to cols columns (0 for no wrapping).
+func (c *Command) InheritedFlagUsagesWrapped(cols int) string {
+	return c.usageFlags(c.InheritedFlags()).FlagUsagesWrapped(cols)
+}
+
+// InheritedFlagUsages returns a string containing the usage information
+// for all persistent flags inherited from parent commands.
+func (c *Command) InheritedFlagUsages() string {
+	return c.InheritedFlagUsagesWrapped(0)
+}
+
 // CommandUsage returns a string containing the usage information
 // for this command and all subcommands, including the
 // description for this command if defined.
@@ -273,16 +697,37 @@ func (c *Command) CommandUsage() string {
 	// Get terminal width to wrap subcommand and flag usages.
 	termWidth, _, _ := getTermSize()
 
+	// Add synopsis and positional arguments.
+	if len(c.args) > 0 {
+		_, _ = fmt.Fprintln(buf, "Usage:")
+		_, _ = fmt.Fprintln(buf, strings.Repeat(" ", commandGapLen)+c.Synopsis())
+		_, _ = fmt.Fprintln(buf, "Arguments:")
+		_, _ = fmt.Fprint(buf, c.ArgUsagesWrapped(termWidth))
+	}
+
 	// Add subcommands.
 	if len(c.commands) > 0 {
 		_, _ = fmt.Fprintln(buf, "Commands:")
 		_, _ = fmt.Fprint(buf, c.CommandUsagesWrapped(termWidth))
 	}
 
+	// Add plugins.
+	if plugins := c.PluginUsagesWrapped(termWidth); len(plugins) > 0 {
+		_, _ = fmt.Fprintln(buf, "Plugin Commands:")
+		_, _ = fmt.Fprint(buf, plugins)
+	}
+
 	// Add flag usages.
-	if c.flags.HasAvailableFlags() {
+	if c.LocalFlags().HasAvailableFlags() {
 		_, _ = fmt.Fprintln(buf, "Flags:")
 		_, _ = fmt.Fprint(buf, c.FlagUsagesWrapped(termWidth))
+		_, _ = fmt.Fprint(buf, c.flagGroupUsages())
+	}
+
+	// Add inherited persistent flag usages.
+	if c.InheritedFlags().HasAvailableFlags() {
+		_, _ = fmt.Fprintln(buf, "Global Flags:")
+		_, _ = fmt.Fprint(buf, c.InheritedFlagUsagesWrapped(termWidth))
 	}
 
 	return buf.String()
@@ -290,7 +735,8 @@ func (c *Command) CommandUsage() string {
 
 // parse parses the command line arguments respecting the defined
 // command structure. Arguments for each command are parsed using pflag.
-// If executeCallback is true, the callback defined for the last active command
+// If executeCallback is true, the positional arguments of each active command
+// are validated and the callback defined for the last active command
 // will be executed (or the global callback if defined).
 func (c *Command) parse(arguments []string, executeCallback bool) error {
 	if len(arguments) == 0 {
@@ -305,58 +751,79 @@ func (c *Command) parse(arguments []string, executeCallback bool) error {
 	// Check if the command name is empty (top-level command)
 	// or matches the first argument (subcommand).
 	if cmd.name != "" && cmd.name != arguments[0] {
-		return fmt.Errorf("Command %q does not match arguments.", cmd.name)
+		return &UnknownCommandError{Command: cmd, Name: arguments[0]}
 	}
 
 	// Mark command as active and remove first argument.
 	cmd.active = true
 	arguments = arguments[1:]
 
+	// Check whether errors must be returned instead of exiting.
+	noExit := c.isNoExit()
+
+	// Check whether Parse expanded the response files already.
+	respExpanded := c.isResponseFiles()
+
 	// Slice to keep track of the chain of active commands.
 	var cmdChain []*Command
 
 	// Parse arguments and handle all commands and flags.
 	for {
-		// Search matching subcommand in arguments.
-		if len(cmd.commands) > 0 && len(arguments) > 0 {
-			for iArg, arg := range arguments {
-				if iCmd := slices.IndexFunc(cmd.commands, func(cmd *Command) bool {
-					return cmd.name == arg
-				}); iCmd >= 0 {
-					// Remember subcommand for next loop
-					// and cache arguments before and after command name.
-					subCmd = cmd.commands[iCmd]
-					argsBeforeSubCmd = arguments[:iArg]
-					argsAfterSubCmd = arguments[iArg+1:]
-					break
-				}
+		// Create flag set and add help and persistent flags.
+		cmd.prepareFlags()
+
+		// Search matching subcommand in arguments. With recurseArgs on,
+		// the arguments may contain flags of the parent commands as well.
+		flagSets := []*flag.FlagSet{cmd.flags}
+		if cmd.recurseArgs {
+			for _, parentCmd := range cmdChain {
+				flagSets = append(flagSets, parentCmd.flags)
 			}
 		}
-
-		// Use all arguments when no subcommand is found.
-		if subCmd == nil {
-			argsBeforeSubCmd = arguments
+		// Plugins take precedence over subcommands named by later arguments.
+		iPlugin, pluginPath := -1, ""
+		if executeCallback {
+			iPlugin, pluginPath = cmd.findPlugin(arguments, flagSets)
 		}
-
-		// Create flag set if unset.
-		if cmd.flags == nil {
-			cmd.flags = NewFlagSet("", flag.ExitOnError)
+		if iPlugin >= 0 {
+			// Parse the arguments before the plugin name only.
+			argsBeforeSubCmd = arguments[:iPlugin]
+		} else if iArg := cmd.findSubCommand(arguments, flagSets); iArg >= 0 {
+			// Remember subcommand for next loop
+			// and cache arguments before and after command name.
+			subCmd = cmd.matchCommand(arguments[iArg])
+			argsBeforeSubCmd = arguments[:iArg]
+			argsAfterSubCmd = arguments[iArg+1:]
 		}
 
-		// Add help flag if unset.
-		if _, err := cmd.flags.GetBool("help"); err != nil {
-			cmd.flags.BoolP("help", "h", false, "Display help.")
+		// Use all arguments when no subcommand or plugin is found.
+		if subCmd == nil && iPlugin < 0 {
+			argsBeforeSubCmd = arguments
 		}
 
 		// Parse command arguments.
-		_ = cmd.flags.Parse(argsBeforeSubCmd)
+		if err := cmd.parseFlags(argsBeforeSubCmd, noExit); err != nil {
+			return &FlagParseError{Command: cmd, Err: err}
+		}
 
 		// Print help and exit when help flag is set.
 		if paramHelp, err := cmd.flags.GetBool("help"); err == nil && paramHelp {
 			cmd.printUsage()
+			if noExit {
+				return ErrHelpRequested
+			}
 			os.Exit(0)
 		}
 
+		// Store and validate positional arguments.
+		// Recursive parsing for child commands must not overwrite them.
+		if executeCallback {
+			cmd.argValues = cmd.flags.Args()
+			if err := cmd.validateArgs(); err != nil {
+				return err
+			}
+		}
+
 		// When recurseArgs is on, parse the arguments for the current command
 		// using all parent commands.
 		if cmd.recurseArgs && len(argsBeforeSubCmd) > 0 {
@@ -365,7 +832,9 @@ func (c *Command) parse(arguments []string, executeCallback bool) error {
 				parentCmd := cmdChain[len(cmdChain)-1-i]
 				parentArgs := slices.Clone(argsBeforeSubCmd)
 				parentArgs = slices.Insert(parentArgs, 0, parentCmd.name)
-				parentCmd.parse(parentArgs, false)
+				if err := parentCmd.parse(parentArgs, false); err != nil {
+					return err
+				}
 			}
 		}
 
@@ -377,6 +846,11 @@ func (c *Command) parse(arguments []string, executeCallback bool) error {
 		// Add command to chain.
 		cmdChain = append(cmdChain, cmd)
 
+		// Run plugin instead of the callbacks.
+		if iPlugin >= 0 {
+			return cmd.execPlugin(arguments[iPlugin], pluginPath, arguments[iPlugin+1:], noExit)
+		}
+
 		// Parse subcommand.
 		if subCmd != nil {
 			// Use subcommand for next parsing loop.
@@ -386,21 +860,45 @@ func (c *Command) parse(arguments []string, executeCallback bool) error {
 			arguments = argsAfterSubCmd
 			argsBeforeSubCmd = nil
 			argsAfterSubCmd = nil
+
+			// Expand response files enabled for the subcommand.
+			if executeCallback && cmd.respFiles && !respExpanded {
+				args, err := expandResponseFiles(arguments)
+				if err != nil {
+					return err
+				}
+				arguments = args
+				respExpanded = true
+			}
 		} else {
 			// No subcommand found. Exit loop.
 			break
 		}
 	}
 
-	// Execute the callback function of the last active command which has a callback defined,
-	// or the global callback function (if defined).
+	// Set flags which were not supplied from the environment
+	// and the configuration file.
 	if executeCallback {
-		for i := range cmdChain {
-			callbackCmd := cmdChain[len(cmdChain)-1-i]
-			if callbackCmd.callback != nil || i == len(cmdChain)-1 {
-				return callbackCmd.execCallback(cmd)
+		for _, chainCmd := range cmdChain {
+			if err := chainCmd.applyEnv(); err != nil {
+				return err
 			}
 		}
+		if err := applyConfig(cmdChain); err != nil {
+			return err
+		}
+	}
+
+	// Check required flags, flag groups and flag values, then
+	// execute the hooks and the callback function of the active commands.
+	if executeCallback {
+		if err := checkFlagConstraints(cmdChain); err != nil {
+			return err
+		}
+		if err := validateFlags(cmdChain); err != nil {
+			return err
+		}
+		return execChain(cmdChain)
 	}
 
 	return nil
@@ -408,10 +906,244 @@ func (c *Command) parse(arguments []string, executeCallback bool) error {
 
 // Parse parses the command line arguments respecting the defined
 // command structure. Arguments for each command are parsed using pflag.
+// When the first argument after the command name is "__complete",
+// the completions for the remaining arguments are written to os.Stdout
+// instead. See Command.RegisterFlagCompletionFunc.
+// With response files enabled, "@path" arguments are expanded first.
+// See Command.SetResponseFiles and Command.SetMultiCall.
 func (c *Command) Parse(arguments []string) error {
+	if c.parent == nil && len(arguments) > 1 && arguments[1] == completeCommandName {
+		return c.execComplete(arguments)
+	}
+	if c.isResponseFiles() && len(arguments) > 1 {
+		args, err := expandResponseFiles(arguments[1:])
+		if err != nil {
+			return err
+		}
+		arguments = append([]string{arguments[0]}, args...)
+	}
+	if c.parent == nil && c.multiCall && len(arguments) > 0 {
+		// Start resolution at the command named like the executable.
+		if cmd := c.Lookup(filepath.Base(arguments[0])); cmd != nil {
+			arguments = append([]string{arguments[0], cmd.name}, arguments[1:]...)
+		}
+	}
 	return c.parse(arguments, true)
 }
 
+// names returns the name and the aliases of the command.
+func (c *Command) names() []string {
+	return append([]string{c.name}, c.aliases...)
+}
+
+// hasName reports whether name equals the name or one of the aliases of the command.
+func (c *Command) hasName(name string) bool {
+	return c.name == name || slices.Contains(c.aliases, name)
+}
+
+// matchCommand searches for a registered subcommand by its name or alias.
+// With prefix matching enabled, a unique prefix of a name or alias
+// matches as well. If no matching command is found, nil is returned.
+func (c *Command) matchCommand(name string) *Command {
+	if cmd := c.Lookup(name); cmd != nil || len(name) == 0 || !c.isPrefixMatching() {
+		return cmd
+	}
+
+	// Find all commands with a name or alias starting with name.
+	matches := filterSlice(c.commands, func(cmd *Command) bool {
+		return slices.ContainsFunc(cmd.names(), func(cmdName string) bool {
+			return strings.HasPrefix(cmdName, name)
+		})
+	})
+	if len(matches) != 1 {
+		return nil
+	}
+
+	return matches[0]
+}
+
+// isPrefixMatching reports whether prefix matching is enabled
+// for c, one of its parent commands or the global command.
+func (c *Command) isPrefixMatching() bool {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if cmd.prefixMatch {
+			return true
+		}
+	}
+	return command.prefixMatch
+}
+
+// findSubCommand returns the index of the first argument naming a subcommand,
+// or -1 if no subcommand is found. Values consumed by flags defined in flagSets
+// are skipped, and all arguments after the "--" terminator are positional.
+// Unknown flags are assumed not to consume a value.
+func (c *Command) findSubCommand(arguments []string, flagSets []*flag.FlagSet) int {
+	if len(c.commands) == 0 {
+		return -1
+	}
+
+	for iArg := 0; iArg < len(arguments); iArg++ {
+		arg := arguments[iArg]
+
+		switch {
+		case arg == "--":
+			// All remaining arguments are positional.
+			return -1
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// Skip the value consumed by the flag.
+			if valueFlag(arg, flagSets) != nil {
+				iArg++
+			}
+		default:
+			// Check whether the argument names a subcommand.
+			if c.matchCommand(arg) != nil {
+				return iArg
+			}
+		}
+	}
+
+	return -1
+}
+
+// prepareFlags creates the flag set of the command if unset and adds the help
+// flag and the persistent flags of the command and all its parent commands.
+func (c *Command) prepareFlags() {
+	// Create flag set if unset.
+	if c.flags == nil {
+		c.flags = NewFlagSet("", flag.ContinueOnError)
+	}
+
+	// Add help flag if unset.
+	if _, err := c.flags.GetBool("help"); err != nil {
+		c.flags.BoolP("help", "h", false, "Display help.")
+	}
+
+	// Add persistent flags of the command and all parent commands.
+	for persistentCmd := c; persistentCmd != nil; persistentCmd = persistentCmd.parent {
+		mergeFlags(c.flags, persistentCmd.persistent)
+	}
+}
+
+// parseFlags parses the flags of the command from arguments. The flag set
+// is owned by the caller and keeps its error handling: in no-exit mode,
+// the arguments are checked by a copy of the flag set returning errors,
+// and errors setting flag values are returned after parsing.
+func (c *Command) parseFlags(arguments []string, noExit bool) error {
+	c.saveFlagStates()
+
+	// Check the arguments and locate the "--" terminator using a copy
+	// of the flag set sharing the flags, which does not set any values.
+	check := flag.NewFlagSet("", flag.ContinueOnError)
+	check.SetOutput(io.Discard)
+	check.ParseErrorsWhitelist = c.flags.ParseErrorsWhitelist
+	check.SetNormalizeFunc(c.flags.GetNormalizeFunc())
+	check.AddFlagSet(c.flags)
+	err := check.ParseAll(arguments, func(*flag.Flag, string) error {
+		return nil
+	})
+	c.argsAtDash = check.ArgsLenAtDash()
+
+	// Let pflag handle errors as configured for the flag set.
+	set := func(f *flag.Flag, value string) error {
+		if err := clearResetFlag(f); err != nil {
+			return err
+		}
+		return c.flags.Set(f.Name, value)
+	}
+	if !noExit {
+		return c.flags.ParseAll(arguments, set)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Set the flag values, keeping the first error.
+	var setErr error
+	_ = c.flags.ParseAll(arguments, func(f *flag.Flag, value string) error {
+		if setErr == nil {
+			setErr = set(f, value)
+		}
+		return nil
+	})
+	return setErr
+}
+
+// addArg appends arg to the declared positional arguments
+// after checking that the declaration order is valid.
+func (c *Command) addArg(arg *Arg) error {
+	if len(arg.Name) == 0 {
+		return fmt.Errorf("invalid parameters")
+	}
+
+	for _, a := range c.args {
+		if a.Name == arg.Name {
+			return fmt.Errorf("argument with name '%s' already exists", arg.Name)
+		}
+		if a.Variadic {
+			return fmt.Errorf("argument '%s' cannot follow variadic argument '%s'", arg.Name, a.Name)
+		}
+		if a.Optional && !arg.Optional {
+			return fmt.Errorf("required argument '%s' cannot follow optional argument '%s'", arg.Name, a.Name)
+		}
+	}
+
+	c.args = append(c.args, arg)
+	return nil
+}
+
+// getArgRange returns the number of positional arguments accepted by the
+// command, either set via SetArgRange or derived from the declared arguments.
+// A negative max means there is no upper limit.
+func (c *Command) getArgRange() (min, max int) {
+	if c.argRange != nil {
+		return c.argRange.min, c.argRange.max
+	}
+
+	for _, arg := range c.args {
+		if arg.Variadic {
+			return min, -1
+		}
+		if !arg.Optional {
+			min++
+		}
+		max++
+	}
+	return min, max
+}
+
+// validateArgs checks the number of supplied positional arguments.
+// Commands without declared arguments or range accept any arguments,
+// unless they have subcommands and suggestions are enabled: then the first
+// argument before the "--" terminator is reported as an unknown command.
+func (c *Command) validateArgs() error {
+	if len(c.args) == 0 && c.argRange == nil {
+		if c.noSuggest {
+			return nil
+		}
+		nArgs := len(c.argValues)
+		if c.argsAtDash >= 0 {
+			nArgs = c.argsAtDash
+		}
+		if len(c.commands) > 0 && nArgs > 0 {
+			name := c.argValues[0]
+			return &UnknownCommandError{Command: c, Name: name, Suggestions: c.SuggestionsFor(name)}
+		}
+		return nil
+	}
+
+	n := len(c.argValues)
+	min, max := c.getArgRange()
+	switch {
+	case max >= 0 && min == max && n != min:
+		return fmt.Errorf("command %q requires exactly %d argument(s), received %d", c.GetCommandPath(), min, n)
+	case n < min:
+		return fmt.Errorf("command %q requires at least %d argument(s), received %d", c.GetCommandPath(), min, n)
+	case max >= 0 && n > max:
+		return fmt.Errorf("command %q accepts at most %d argument(s), received %d", c.GetCommandPath(), max, n)
+	}
+	return nil
+}
+
 // printUsage calls the function defined via Command.SetUsageFunc
 // or SetUsageFunc, or defaultUsage when both are undefined.
 func (c *Command) printUsage() {
@@ -424,10 +1156,64 @@ func (c *Command) printUsage() {
 	}
 }
 
+// execChain executes the pre-run hooks, the callback function of the last
+// active command which has a callback defined, or the global callback function
+// (if defined), and the post-run hooks for the chain of active commands.
+// The post-run hooks are executed even if the callback returns an error.
+func execChain(cmdChain []*Command) error {
+	cmd := cmdChain[len(cmdChain)-1]
+
+	// Execute the pre-run hooks top-down.
+	for _, hookCmd := range cmdChain {
+		if hookCmd.pPreRun != nil {
+			if err := hookCmd.pPreRun(cmd, cmd.flags); err != nil {
+				return err
+			}
+		}
+	}
+	if cmd.preRun != nil {
+		if err := cmd.preRun(cmd, cmd.flags); err != nil {
+			return err
+		}
+	}
+
+	// Execute the callback.
+	var errs []error
+	for i := range cmdChain {
+		callbackCmd := cmdChain[len(cmdChain)-1-i]
+		if callbackCmd.callback != nil || i == len(cmdChain)-1 {
+			if err := callbackCmd.execCallback(cmd); err != nil {
+				errs = append(errs, err)
+			}
+			break
+		}
+	}
+
+	// Execute the post-run hooks bottom-up.
+	if cmd.postRun != nil {
+		if err := cmd.postRun(cmd, cmd.flags); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for i := range cmdChain {
+		hookCmd := cmdChain[len(cmdChain)-1-i]
+		if hookCmd.pPostRun != nil {
+			if err := hookCmd.pPostRun(cmd, cmd.flags); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
 // execCallback runs the callback defined via Command.SetCallback or SetCallback.
 // When a target is supplied, it is passed to the callback instead of the command itself.
 func (c *Command) execCallback(target *Command) error {
-	var cb CommandCallback
+	var cb ContextCallback
 	var cmd *Command
 
 	// Use either the callback defined for this command or the
@@ -448,7 +1234,48 @@ func (c *Command) execCallback(target *Command) error {
 	}
 
 	// Execute the callback.
-	return cb(cmd, cmd.flags)
+	return cb(cmd.Context(), cmd, cmd.flags)
+}
+
+// lookupFlag searches the flags, the persistent flags and the inherited
+// flags of the command for the flag name. If no flag is found, nil is returned.
+func (c *Command) lookupFlag(name string) *flag.Flag {
+	for _, flags := range []*flag.FlagSet{c.flags, c.persistent} {
+		if flags == nil {
+			continue
+		}
+		if f := flags.Lookup(name); f != nil {
+			return f
+		}
+	}
+	return c.InheritedFlags().Lookup(name)
+}
+
+// flagOwner returns the topmost command of the chain from c to the
+// top-level command which defines f, or nil if no command defines f.
+// After parsing, inherited persistent flags are part of the flag sets
+// of subcommands as well.
+func (c *Command) flagOwner(f *flag.Flag) *Command {
+	var owner *Command
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		for _, flags := range []*flag.FlagSet{cmd.flags, cmd.persistent} {
+			if flags != nil && flags.Lookup(f.Name) == f {
+				owner = cmd
+			}
+		}
+	}
+	return owner
+}
+
+// isNoExit reports whether the no-exit mode is enabled
+// for c, one of its parent commands or the global command.
+func (c *Command) isNoExit() bool {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if cmd.noExit {
+			return true
+		}
+	}
+	return command.noExit
 }
 
 // out returns the output stream defined for c or the global command,
@@ -465,6 +1292,7 @@ func (c *Command) out() io.Writer {
 
 // NewFlagSet creates a flag.FlagSet with ParseErrorsWhitelist.UnknownFlags enabled,
 // which is required to process subcommands.
+// With flag.ContinueOnError, parsing errors are returned by Parse as *FlagParseError.
 func NewFlagSet(name string, errorHandling flag.ErrorHandling) *flag.FlagSet {
 	flagSet := flag.NewFlagSet(name, errorHandling)
 	flagSet.ParseErrorsWhitelist.UnknownFlags = true
@@ -485,8 +1313,8 @@ func NewCommand(name string, usage string, flags *flag.FlagSet) *Command {
 // AddCommand adds command to the global register.
 // When a command with the same name already exists,
 // the operation is cancelled and an error is returned.
-func AddCommand(command *Command) error {
-	return command.AddCommand(command)
+func AddCommand(cmd *Command) error {
+	return command.AddCommand(cmd)
 }
 
 // Cmd creates and adds a new command to the global register.
@@ -496,6 +1324,31 @@ func Cmd(name string, usage string, flags *flag.FlagSet) (*Command, error) {
 	return command.Cmd(name, usage, flags)
 }
 
+// AddArg declares a required top-level positional argument.
+// See Command.AddArg.
+func AddArg(name string, usage string) error {
+	return command.AddArg(name, usage)
+}
+
+// AddOptionalArg declares an optional top-level positional argument.
+// See Command.AddOptionalArg.
+func AddOptionalArg(name string, usage string) error {
+	return command.AddOptionalArg(name, usage)
+}
+
+// AddVariadicArg declares a top-level positional argument which receives
+// all remaining arguments. See Command.AddVariadicArg.
+func AddVariadicArg(name string, usage string) error {
+	return command.AddVariadicArg(name, usage)
+}
+
+// SetArgRange overrides the number of top-level positional arguments
+// accepted by the application. See Command.SetArgRange.
+func SetArgRange(min, max int) *Command {
+	command.SetArgRange(min, max)
+	return &command
+}
+
 // SetDescription defines a long description that is
 // displayed on the generated help page. See CommandUsages.
 func SetDescription(description string) *Command {
@@ -521,6 +1374,41 @@ func SetCallback(callback CommandCallback) *Command {
 	return &command
 }
 
+// SetContextCallback sets the global callback like SetCallback, which receives
+// the context passed to ExecuteContext. See Command.SetContextCallback.
+func SetContextCallback(callback ContextCallback) *Command {
+	command.SetContextCallback(callback)
+	return &command
+}
+
+// SetPreRun sets a hook which is executed before the callback
+// when no command is supplied. See Command.SetPreRun.
+func SetPreRun(hook CommandCallback) *Command {
+	command.SetPreRun(hook)
+	return &command
+}
+
+// SetPostRun sets a hook which is executed after the callback
+// when no command is supplied. See Command.SetPostRun.
+func SetPostRun(hook CommandCallback) *Command {
+	command.SetPostRun(hook)
+	return &command
+}
+
+// SetPersistentPreRun sets a hook which is executed before the callback
+// of every command. See Command.SetPersistentPreRun.
+func SetPersistentPreRun(hook CommandCallback) *Command {
+	command.SetPersistentPreRun(hook)
+	return &command
+}
+
+// SetPersistentPostRun sets a hook which is executed after the callback
+// of every command. See Command.SetPersistentPostRun.
+func SetPersistentPostRun(hook CommandCallback) *Command {
+	command.SetPersistentPostRun(hook)
+	return &command
+}
+
 // SetOutput sets the destination for usage and error messages.
 // If output is nil, os.Stderr is used.
 func SetOutput(output io.Writer) *Command {
@@ -528,6 +1416,48 @@ func SetOutput(output io.Writer) *Command {
 	return &command
 }
 
+// SetPersistentFlags defines a set of flags which is accepted by the
+// application and all commands. See Command.SetPersistentFlags.
+func SetPersistentFlags(flags *flag.FlagSet) *Command {
+	command.SetPersistentFlags(flags)
+	return &command
+}
+
+// SetPrefixMatching enables matching commands by a unique prefix
+// of their names or aliases. See Command.SetPrefixMatching.
+func SetPrefixMatching() *Command {
+	command.SetPrefixMatching()
+	return &command
+}
+
+// SetMultiCall enables the multi-call mode for the application,
+// selecting commands by the name of the executable. See Command.SetMultiCall.
+func SetMultiCall() *Command {
+	command.SetMultiCall()
+	return &command
+}
+
+// SetSuggestionDistance sets the maximum edit distance between an unknown
+// command name and the names of the commands to suggest them.
+// See Command.SetSuggestionDistance.
+func SetSuggestionDistance(distance int) *Command {
+	command.SetSuggestionDistance(distance)
+	return &command
+}
+
+// DisableSuggestions disables detecting unknown command names and
+// suggesting commands. See Command.DisableSuggestions.
+func DisableSuggestions() *Command {
+	command.DisableSuggestions()
+	return &command
+}
+
+// SetNoExit enables the no-exit mode for the application. See Command.SetNoExit.
+func SetNoExit() *Command {
+	command.SetNoExit()
+	return &command
+}
+
 // IsActive reports whether the global command is active, i.e. Parse has been called.
 func IsActive() bool {
 	return command.IsActive()
@@ -539,7 +1469,18 @@ func GetDescription() string {
 	return command.GetDescription()
 }
 
-// Lookup searches for a registered command by its name.
+// GetArgs returns all top-level positional arguments supplied to the application.
+func GetArgs() []string {
+	return command.GetArgs()
+}
+
+// GetArg returns the value supplied for the declared top-level
+// positional argument name. See Command.GetArg.
+func GetArg(name string) string {
+	return command.GetArg(name)
+}
+
+// Lookup searches for a registered command by its name or alias.
 // If no matching command is found, nil is returned.
 func Lookup(name string) *Command {
 	return command.Lookup(name)
@@ -591,6 +1532,166 @@ func Reset() {
 	command = Command{}
 }
 
+// ResetState resets the state of the command and all its subcommands set
+// by Parse, so that the command tree can be parsed again: the commands are
+// marked inactive, the positional argument values, flag sources and the
+// loaded configuration file are cleared, and all flags are restored to
+// their default values and marked unchanged.
+//
+// Map and IP values are restored to the state recorded before Parse set
+// them for the first time. Maps with an empty default cannot be cleared
+// once set, for these flags an error is returned. The flag set methods
+// Visit and NFlag keep reporting the flags set before the reset.
+func (c *Command) ResetState() error {
+	var errs []error
+
+	c.active = false
+	c.argValues = nil
+	c.sources = nil
+	c.configFile = ""
+	states := c.root().flagStates
+	for _, flags := range []*flag.FlagSet{c.flags, c.persistent} {
+		if flags == nil {
+			continue
+		}
+		flags.VisitAll(func(f *flag.Flag) {
+			if err := resetFlag(f, states[f]); err != nil {
+				errs = append(errs, fmt.Errorf("command %q: cannot reset flag '%s': %w", c.GetCommandPath(), f.Name, err))
+			}
+		})
+	}
+
+	for _, cmd := range c.commands {
+		if err := cmd.ResetState(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ResetState resets the state of the application set by Parse.
+// See Command.ResetState.
+func ResetState() error {
+	return command.ResetState()
+}
+
+// The flag annotation marking slice flags restored by ResetState.
+const resetAnnotation = "cflag_reset"
+
+// resetFlag restores the default value of f and marks it unchanged.
+// state is the value state recorded by saveFlagStates, if any.
+func resetFlag(f *flag.Flag, state reflect.Value) error {
+	f.Changed = false
+
+	// Replace slice values by the default elements. Once set, pflag appends
+	// to slice values, so they are marked to be cleared before the next value
+	// is set. See clearResetFlag.
+	if slice, ok := f.Value.(flag.SliceValue); ok {
+		def, err := splitDefault(f.DefValue)
+		if err != nil {
+			return err
+		}
+		if err := slice.Replace(def); err != nil {
+			return err
+		}
+		if f.Annotations == nil {
+			f.Annotations = make(map[string][]string)
+		}
+		f.Annotations[resetAnnotation] = nil
+		return nil
+	}
+
+	if !state.IsValid() {
+		// Keep values holding the default value.
+		if f.Value.String() == f.DefValue {
+			return nil
+		}
+		return f.Value.Set(f.DefValue)
+	}
+
+	// Restore the recorded state. Map values only hold a pointer to the map,
+	// so the default entries are set again, which replaces the map as long
+	// as the value is unchanged.
+	value := reflect.ValueOf(f.Value).Elem()
+	value.Set(state)
+	if !strings.HasPrefix(f.Value.Type(), "stringTo") || isDefaultMap(f) {
+		return nil
+	}
+	if f.DefValue == "[]" {
+		return fmt.Errorf("maps with an empty default cannot be cleared")
+	}
+	if err := f.Value.Set(strings.TrimSuffix(strings.TrimPrefix(f.DefValue, "["), "]")); err != nil {
+		return err
+	}
+	value.Set(state)
+	return nil
+}
+
+// isDefaultMap reports whether the map flag f holds the entries
+// of its default value, which pflag lists in random order.
+func isDefaultMap(f *flag.Flag) bool {
+	value, _ := splitDefault(f.Value.String())
+	def, _ := splitDefault(f.DefValue)
+	slices.Sort(value)
+	slices.Sort(def)
+	return slices.Equal(value, def)
+}
+
+// isStateFlag reports whether f holds a map or IP value, which cannot be
+// restored through the flag.Value interface once set.
+func isStateFlag(f *flag.Flag) bool {
+	switch typ := f.Value.Type(); typ {
+	case "ip", "ipNet", "ipMask":
+		return true
+	default:
+		return strings.HasPrefix(typ, "stringTo")
+	}
+}
+
+// saveFlagStates records the state of the map and IP values of the command
+// which were not recorded yet, so that ResetState can restore them. It is
+// called before the values are set by Parse.
+func (c *Command) saveFlagStates() {
+	root := c.root()
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if _, ok := root.flagStates[f]; ok || !isStateFlag(f) {
+			return
+		}
+		value := reflect.ValueOf(f.Value)
+		if value.Kind() != reflect.Pointer {
+			return
+		}
+		state := reflect.New(value.Elem().Type()).Elem()
+		state.Set(value.Elem())
+		if root.flagStates == nil {
+			root.flagStates = make(map[*flag.Flag]reflect.Value)
+		}
+		root.flagStates[f] = state
+	})
+}
+
+// splitDefault returns the comma separated elements of a slice
+// or map default value enclosed in brackets, e.g. "[a,b]".
+func splitDefault(def string) ([]string, error) {
+	def = strings.TrimSuffix(strings.TrimPrefix(def, "["), "]")
+	if len(def) == 0 {
+		return nil, nil
+	}
+	return csv.NewReader(strings.NewReader(def)).Read()
+}
+
+// clearResetFlag clears the value of the slice flag f if it was restored by
+// ResetState, so that the next value set replaces the default value instead
+// of being appended to it.
+func clearResetFlag(f *flag.Flag) error {
+	if _, ok := f.Annotations[resetAnnotation]; !ok {
+		return nil
+	}
+	delete(f.Annotations, resetAnnotation)
+	return f.Value.(flag.SliceValue).Replace(nil)
+}
+
 // defaultUsage prints, to standard error unless configured
 // otherwise, the default values of all defined flags in the set.
 // This is the default function to print a usage message.
@@ -598,6 +1699,114 @@ func defaultUsage(command *Command) {
 	_, _ = fmt.Fprint(command.out(), command.CommandUsage())
 }
 
+// usageFlags returns a copy of flags for usage messages, where the usage
+// of each flag is extended by the bound environment variable, the accepted
+// values and a marker for required flags.
+func (c *Command) usageFlags(flags *flag.FlagSet) *flag.FlagSet {
+	res := NewFlagSet("", flag.ContinueOnError)
+	res.SortFlags = flags.SortFlags
+	flags.VisitAll(func(f *flag.Flag) {
+		usage := f.Usage
+		if env := c.flagEnv(f); len(env) > 0 && f.Name != "help" {
+			usage += " [$" + env + "]"
+		}
+		if values := c.enumValues(f.Name); len(values) > 0 {
+			usage += " (one of: " + strings.Join(values, ", ") + ")"
+		}
+		if isFlagRequired(f) {
+			usage += " (required)"
+		}
+		if usage != f.Usage {
+			clone := *f
+			clone.Usage = usage
+			f = &clone
+		}
+		res.AddFlag(f)
+	})
+	return res
+}
+
+// mergeFlags adds all flags of src to dst, skipping flags whose
+// name or shorthand is already defined in dst.
+func mergeFlags(dst, src *flag.FlagSet) {
+	if src == nil {
+		return
+	}
+	src.VisitAll(func(f *flag.Flag) {
+		mergeFlag(dst, f)
+	})
+}
+
+// mergeFlag adds f to dst unless its name or shorthand is already defined in dst.
+func mergeFlag(dst *flag.FlagSet, f *flag.Flag) {
+	if dst.Lookup(f.Name) != nil {
+		return
+	}
+	if len(f.Shorthand) > 0 && dst.ShorthandLookup(f.Shorthand) != nil {
+		return
+	}
+	dst.AddFlag(f)
+}
+
+// valueFlag returns the flag defined in flagSets which consumes the argument
+// following the flag argument arg, or nil if arg does not consume the next argument.
+// '--flag arg' and '-f arg' consume the next argument, '--flag=arg', '-f=arg',
+// '-farg' and flags with an optional argument do not.
+// Unknown flags are assumed not to consume a value.
+func valueFlag(arg string, flagSets []*flag.FlagSet) *flag.Flag {
+	// lookupFlag searches flagSets for a flag by its name or shorthand.
+	lookupFlag := func(name string, shorthand bool) *flag.Flag {
+		for _, flags := range flagSets {
+			var f *flag.Flag
+			if shorthand {
+				f = flags.ShorthandLookup(name)
+			} else {
+				f = flags.Lookup(name)
+			}
+			if f != nil {
+				return f
+			}
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(arg, "--") {
+		name := arg[2:]
+		if strings.Contains(name, "=") {
+			return nil
+		}
+		if f := lookupFlag(name, false); f != nil && len(f.NoOptDefVal) == 0 {
+			return f
+		}
+		return nil
+	}
+
+	// Skip test flags like pflag does.
+	shorthands := strings.TrimPrefix(arg, "-")
+	if strings.HasPrefix(shorthands, "test.") {
+		return nil
+	}
+
+	// Walk the shorthand letters (e.g. "-vvv"). Only the last letter
+	// of a series may consume the next argument.
+	for len(shorthands) > 0 {
+		f := lookupFlag(shorthands[:1], true)
+		if len(shorthands) > 1 && shorthands[1] == '=' {
+			return nil
+		}
+		if f == nil || len(f.NoOptDefVal) > 0 {
+			shorthands = shorthands[1:]
+			continue
+		}
+		if len(shorthands) == 1 {
+			return f
+		}
+		return nil
+	}
+
+	return nil
+}
+
 // filterSlice filters out all elements where test returns false.
 func filterSlice[T any](slice []T, test func(T) bool) []T {
 	var res []T
@@ -609,6 +1818,68 @@ func filterSlice[T any](slice []T, test func(T) bool) []T {
 	return res
 }
 
+// usageTable returns a string containing one line per row,
+// with the names aligned in the first column and the usages in the second column.
+// Usages are wrapped to cols columns (0 for no wrapping).
+func usageTable(rows [][2]string, cols int) string {
+	buf := new(bytes.Buffer)
+
+	// Find maximum name length to calculate gap width.
+	maxNameLen := 0
+	for _, row := range rows {
+		nameLen := len(row[0])
+		if nameLen > maxNameLen {
+			maxNameLen = nameLen
+		}
+	}
+
+	// Get the full gap until usages are printed for wrapping.
+	fullUsageGapLen := commandGapLen + maxNameLen + commandUsageGapLen
+
+	// Create line containing name and usage.
+	for _, row := range rows {
+		nameLen := len(row[0])
+		gap := strings.Repeat(" ", commandGapLen)
+		usageGapLen := maxNameLen - nameLen + commandUsageGapLen
+		usageGap := strings.Repeat(" ", usageGapLen)
+		usage := wrap(fullUsageGapLen, cols, row[1])
+		_, _ = fmt.Fprintln(buf, gap+row[0]+usageGap+usage)
+	}
+
+	// Return usages string.
+	return buf.String()
+}
+
+// levenshtein returns the edit distance between a and b, i.e. the minimum
+// number of single-byte insertions, deletions and substitutions.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
 // getTermSize determines the dimensions of the active terminal.
 func getTermSize() (width, height int, err error) {
 	fd := int(os.Stdout.Fd())
@@ -1,9 +1,13 @@
 package cflag
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"path/filepath"
 	"slices"
 	"testing"
 
@@ -196,6 +200,56 @@ func TestParse(t *testing.T) {
 	a.Equal(3, *ctx.paramTest3)
 }
 
+func TestParseAmbiguous(t *testing.T) {
+	a := assert.New(t)
+
+	tests := []struct {
+		args       []string
+		wantFoo    bool
+		wantFooBar bool
+		wantName   string
+		wantArgs   []string
+	}{
+		{[]string{"foo"}, true, false, "", nil},
+		{[]string{"--name", "foo"}, false, false, "foo", nil},
+		{[]string{"--name=foo", "foo"}, true, false, "foo", nil},
+		{[]string{"-n", "foo"}, false, false, "foo", nil},
+		{[]string{"-nfoo", "foo"}, true, false, "foo", nil},
+		{[]string{"-vn", "foo"}, false, false, "foo", nil},
+		{[]string{"-v", "foo"}, true, false, "", nil},
+		{[]string{"--version", "foo", "bar"}, true, true, "", nil},
+		{[]string{"--", "foo"}, false, false, "", []string{"foo"}},
+		{[]string{"--name", "foo", "--", "foo"}, false, false, "foo", []string{"foo"}},
+		{[]string{"foo", "--name", "bar"}, true, false, "bar", nil},
+		{[]string{"foo", "--", "bar"}, true, false, "", nil},
+		{[]string{"foo", "--test1", "1", "bar"}, true, true, "", nil},
+	}
+
+	for _, test := range tests {
+		ctx := buildTestContext()
+
+		// Define a persistent string flag which may consume a command name.
+		flagsGlobal := NewFlagSet("", flag.ContinueOnError)
+		paramName := flagsGlobal.StringP("name", "n", "", "Name.")
+		SetPersistentFlags(flagsGlobal)
+
+		// Run cflag parser.
+		a.Nil(Parse(append(ctx.arguments, test.args...), ctx.flags))
+
+		// Print state.
+		t.Logf("%v: foo:%t foo/bar:%t name:%q args:%v\n", test.args,
+			ctx.cmdFoo.IsActive(), ctx.cmdFooBar.IsActive(), *paramName, GetArgs())
+
+		// Check state.
+		a.Equal(test.wantFoo, ctx.cmdFoo.IsActive(), test.args)
+		a.Equal(test.wantFooBar, ctx.cmdFooBar.IsActive(), test.args)
+		a.Equal(test.wantName, *paramName, test.args)
+		if test.wantArgs != nil {
+			a.Equal(test.wantArgs, GetArgs(), test.args)
+		}
+	}
+}
+
 func TestTypes(t *testing.T) {
 	a := assert.New(t)
 	ctx := buildTestContext()
@@ -593,6 +647,40 @@ func TestCallback2(t *testing.T) {
 	a.Nil(Parse(ctx.arguments, ctx.flags))
 }
 
+func TestHooks(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Record the order of executed hooks and callbacks.
+	var calls []string
+	hook := func(name string, err error) CommandCallback {
+		return func(command *Command, flags *flag.FlagSet) error {
+			a.Equal(ctx.cmdFooBar, command)
+			calls = append(calls, name)
+			return err
+		}
+	}
+	errCallback := fmt.Errorf("callback failed")
+	errPostRun := fmt.Errorf("post-run failed")
+	SetPersistentPreRun(hook("app-pre", nil)).SetPersistentPostRun(hook("app-post", nil))
+	ctx.cmdFoo.SetPersistentPreRun(hook("foo-pre", nil)).SetPersistentPostRun(hook("foo-post", errPostRun))
+	ctx.cmdFoo.SetPreRun(hook("foo-pre-leaf", nil)).SetCallback(hook("foo", errCallback))
+	ctx.cmdFooBar.SetPreRun(hook("bar-pre", nil)).SetPostRun(hook("bar-post", nil))
+
+	// Run cflag parser. Post-run hooks run although the callback failed.
+	err := Parse(append(ctx.arguments, "foo", "bar"), ctx.flags)
+	t.Log(err)
+	a.ErrorIs(err, errCallback)
+	a.ErrorIs(err, errPostRun)
+	a.Equal([]string{"app-pre", "foo-pre", "bar-pre", "foo", "bar-post", "foo-post", "app-post"}, calls)
+
+	// A failing pre-run hook skips the callback and post-run hooks.
+	calls = nil
+	ctx.cmdFoo.SetPersistentPreRun(hook("foo-pre", errCallback))
+	a.ErrorIs(Parse(append(ctx.arguments, "foo", "bar"), ctx.flags), errCallback)
+	a.Equal([]string{"app-pre", "foo-pre"}, calls)
+}
+
 func TestStandalone(t *testing.T) {
 	a := assert.New(t)
 
@@ -660,3 +748,441 @@ func TestExample(t *testing.T) {
 	a.Equal(11, *paramFooTest1)
 	a.Equal(12, *paramFooBarTest2)
 }
+
+func TestArgs(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Declare positional arguments for the 'world' command.
+	a.NoError(ctx.cmdWorld.AddArg("src", "Source."))
+	a.NoError(ctx.cmdWorld.AddVariadicArg("dst", "Destinations."))
+
+	// Setup test arguments.
+	ctx.arguments = append(ctx.arguments,
+		[]string{"world", "a", "--test3", "13", "b", "c"}...,
+	)
+
+	// Run cflag parser.
+	a.Nil(Parse(ctx.arguments, ctx.flags))
+
+	// Print arguments.
+	t.Logf("Args: %v\n", ctx.cmdWorld.GetArgs())
+	t.Logf("src: %s\n", ctx.cmdWorld.GetArg("src"))
+	t.Logf("dst: %v\n", ctx.cmdWorld.GetArgValues("dst"))
+
+	// Check argument values.
+	a.Equal([]string{"a", "b", "c"}, ctx.cmdWorld.GetArgs())
+	a.Equal("a", ctx.cmdWorld.GetArg("src"))
+	a.Equal([]string{"b", "c"}, ctx.cmdWorld.GetArgValues("dst"))
+	a.Equal(13, *ctx.paramTest3)
+}
+
+func TestArgsArity(t *testing.T) {
+	a := assert.New(t)
+
+	tests := []struct {
+		args    []string
+		min     int
+		max     int
+		wantErr bool
+	}{
+		{[]string{"world"}, 1, 2, true},
+		{[]string{"world", "a"}, 1, 2, false},
+		{[]string{"world", "a", "b"}, 1, 2, false},
+		{[]string{"world", "a", "b", "c"}, 1, 2, true},
+		{[]string{"world", "a", "b", "c"}, 1, -1, false},
+		{[]string{"world", "a", "b"}, 3, 3, true},
+	}
+
+	for _, test := range tests {
+		ctx := buildTestContext()
+
+		// Declare positional arguments for the 'world' command.
+		a.NoError(ctx.cmdWorld.AddArg("src", "Source."))
+		a.NoError(ctx.cmdWorld.AddVariadicArg("dst", "Destinations."))
+		ctx.cmdWorld.SetArgRange(test.min, test.max)
+
+		// Run cflag parser.
+		err := Parse(append(ctx.arguments, test.args...), ctx.flags)
+		t.Logf("%v [%d,%d]: %v\n", test.args, test.min, test.max, err)
+		if test.wantErr {
+			a.Error(err)
+		} else {
+			a.NoError(err)
+		}
+	}
+
+	// Check declaration order.
+	cmd := NewCommand("", "Test.", nil)
+	a.NoError(cmd.AddOptionalArg("opt", "Optional."))
+	a.Error(cmd.AddArg("req", "Required."))
+	a.NoError(cmd.AddVariadicArg("rest", "Rest."))
+	a.Error(cmd.AddOptionalArg("opt2", "Optional 2."))
+	a.Error(cmd.AddOptionalArg("opt", "Optional."))
+}
+
+func TestArgsHelp(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Declare positional arguments for the 'foo' command.
+	a.NoError(ctx.cmdFoo.AddArg("src", "Source."))
+	a.NoError(ctx.cmdFoo.AddVariadicArg("dst", "Destinations."))
+
+	// Check synopsis and help output.
+	output := ctx.cmdFoo.CommandUsage()
+	t.Log(output)
+	a.Equal(filepath.Base(os.Args[0])+" foo <src> [dst...]", ctx.cmdFoo.Synopsis())
+	a.Contains(output, ctx.cmdFoo.Synopsis())
+	a.Contains(output, "Arguments:")
+	a.Contains(output, "Destinations.")
+}
+
+func TestNoExit(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Enable no-exit mode and redirect help output.
+	buf := new(bytes.Buffer)
+	SetNoExit().SetOutput(buf)
+
+	// Help returns an error instead of exiting.
+	err := Parse(append(slices.Clone(ctx.arguments), "foo", "--help"), ctx.flags)
+	t.Log(buf.String())
+	a.ErrorIs(err, ErrHelpRequested)
+	a.Contains(buf.String(), "Foo command.")
+
+	// Flag errors are returned as *FlagParseError.
+	err = Parse(append(slices.Clone(ctx.arguments), "foo", "--test1", "abc"), ctx.flags)
+	t.Log(err)
+	var flagErr *FlagParseError
+	a.ErrorAs(err, &flagErr)
+	a.Equal(ctx.cmdFoo, flagErr.Command)
+
+	// The error handling of the flag sets is kept.
+	flagsPanic := NewFlagSet("panic", flag.PanicOnError)
+	flagsPanic.Int("int", 0, "Int.")
+	err = NewCommand("", "", flagsPanic).SetNoExit().Parse([]string{"app", "--int", "abc"})
+	a.ErrorAs(err, &flagErr)
+	err = NewCommand("", "", flagsPanic).SetNoExit().Parse([]string{"app", "--int"})
+	a.ErrorAs(err, &flagErr)
+	a.Panics(func() {
+		_ = flagsPanic.Parse([]string{"--int", "abc"})
+	})
+}
+
+func TestUnknownCommandError(t *testing.T) {
+	a := assert.New(t)
+
+	// Create command with a name that does not match the arguments.
+	cmd := NewCommand("foo", "Foo.", nil)
+
+	// Run cflag parser.
+	err := cmd.Parse([]string{"bar"})
+	t.Log(err)
+
+	// Check error.
+	var cmdErr *UnknownCommandError
+	a.ErrorAs(err, &cmdErr)
+	a.Equal("bar", cmdErr.Name)
+}
+
+func TestPersistentFlags(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Define persistent flags for the application and the 'foo' command.
+	flagsGlobal := NewFlagSet("", flag.ContinueOnError)
+	paramVerbose := flagsGlobal.BoolP("verbose", "V", false, "Verbose output.")
+	SetPersistentFlags(flagsGlobal)
+	flagsFooGlobal := NewFlagSet("", flag.ContinueOnError)
+	paramName := flagsFooGlobal.String("name", "", "Name.")
+	ctx.cmdFoo.SetPersistentFlags(flagsFooGlobal)
+
+	cbFooBar := func(command *Command, flags *flag.FlagSet) error {
+		// Read inherited flags from the active command.
+		verbose, err := flags.GetBool("verbose")
+		a.NoError(err)
+		name, err := command.GetFlags().GetString("name")
+		a.NoError(err)
+		t.Logf("verbose: %t name: %s\n", verbose, name)
+
+		// Check parsed values.
+		a.True(verbose)
+		a.Equal("test", name)
+		return nil
+	}
+	ctx.cmdFooBar.SetCallback(cbFooBar)
+
+	// Setup test arguments.
+	ctx.arguments = append(ctx.arguments,
+		[]string{"foo", "bar", "--verbose", "--name", "test", "--test2", "12"}...,
+	)
+
+	// Run cflag parser.
+	a.Nil(Parse(ctx.arguments, ctx.flags))
+
+	// Check flag values.
+	a.True(ctx.cmdFooBar.IsActive())
+	a.True(*paramVerbose)
+	a.True(flagsGlobal.Changed("verbose"))
+	a.Equal("test", *paramName)
+	a.Equal(12, *ctx.paramTest2)
+
+	// Check help output.
+	output := ctx.cmdFooBar.CommandUsage()
+	t.Log(output)
+	a.Contains(output, "Global Flags:")
+	a.Contains(ctx.cmdFooBar.InheritedFlagUsages(), "--verbose")
+	a.Contains(ctx.cmdFooBar.InheritedFlagUsages(), "--name")
+	a.NotContains(ctx.cmdFooBar.FlagUsages(), "--verbose")
+	a.Contains(ctx.cmdFoo.FlagUsages(), "--name")
+	a.NotContains(ctx.cmdFoo.InheritedFlagUsages(), "--name")
+}
+
+func TestAliases(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Define aliases for the 'world' command.
+	a.NoError(ctx.cmdWorld.SetAliases("w", "earth"))
+
+	// Aliases colliding with other commands are rejected.
+	a.Error(ctx.cmdTypes.SetAliases("w"))
+	cmdOther := NewCommand("other", "Other command.", nil)
+	a.NoError(cmdOther.SetAliases("earth"))
+	a.Error(AddCommand(cmdOther))
+
+	// Setup test arguments.
+	ctx.arguments = append(ctx.arguments,
+		[]string{"earth", "--test3", "13"}...,
+	)
+
+	// Run cflag parser.
+	a.Nil(Parse(ctx.arguments, ctx.flags))
+
+	// Check command state and help output.
+	a.True(ctx.cmdWorld.IsActive())
+	a.Equal(13, *ctx.paramTest3)
+	a.Equal(ctx.cmdWorld, Lookup("w"))
+	output := CommandUsages()
+	t.Log(output)
+	a.Contains(output, "world, w, earth")
+}
+
+func TestPrefixMatching(t *testing.T) {
+	a := assert.New(t)
+
+	tests := []struct {
+		args      []string
+		wantWorld bool
+		wantFoo   bool
+		wantErr   bool
+	}{
+		{[]string{"wor"}, true, false, false},
+		{[]string{"fo"}, false, false, true},
+		{[]string{"foo"}, false, true, false},
+		{[]string{"x"}, false, false, true},
+	}
+
+	for _, test := range tests {
+		ctx := buildTestContext()
+		SetPrefixMatching()
+
+		// Add command sharing the prefix 'fo' with 'foo'.
+		_, err := Cmd("food", "Food command.", nil)
+		a.NoError(err)
+
+		// Run cflag parser. Ambiguous prefixes are unknown commands.
+		err = Parse(append(ctx.arguments, test.args...), ctx.flags)
+		t.Logf("%v: world:%t foo:%t err:%v\n", test.args, ctx.cmdWorld.IsActive(), ctx.cmdFoo.IsActive(), err)
+		a.Equal(test.wantErr, err != nil, test.args)
+
+		// Check command state.
+		a.Equal(test.wantWorld, ctx.cmdWorld.IsActive(), test.args)
+		a.Equal(test.wantFoo, ctx.cmdFoo.IsActive(), test.args)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	a := assert.New(t)
+
+	tests := []struct {
+		args     []string
+		distance int
+		disable  bool
+		wantErr  string
+	}{
+		{[]string{"wrold"}, 0, false, `unknown command "wrold", did you mean "world"?`},
+		{[]string{"fo"}, 0, false, `unknown command "fo", did you mean "foo"?`},
+		{[]string{"typo"}, 0, false, `unknown command "typo"`},
+		{[]string{"earht"}, 0, false, `unknown command "earht", did you mean "world"?`},
+		{[]string{"xyz"}, 0, false, `unknown command "xyz"`},
+		{[]string{"wrold"}, 1, false, `unknown command "wrold"`},
+		{[]string{"wrold"}, 0, true, ""},
+		{[]string{"foo", "baz"}, 0, false, `unknown command "baz", did you mean "bar"?`},
+		{[]string{"--", "wrold"}, 0, false, ""},
+	}
+
+	for _, test := range tests {
+		ctx := buildTestContext()
+		a.NoError(ctx.cmdWorld.SetAliases("earth"))
+
+		// Hidden commands are not suggested.
+		ctx.cmdTypes.MarkHidden()
+		SetSuggestionDistance(test.distance)
+		if test.disable {
+			DisableSuggestions()
+		}
+
+		// Run cflag parser.
+		err := Parse(append(ctx.arguments, test.args...), ctx.flags)
+		t.Logf("%v: %v\n", test.args, err)
+
+		// Check error message.
+		if len(test.wantErr) == 0 {
+			a.NoError(err, test.args)
+			continue
+		}
+		var cmdErr *UnknownCommandError
+		a.ErrorAs(err, &cmdErr, test.args)
+		a.EqualError(err, test.wantErr, test.args)
+	}
+}
+
+func TestUnknownCommandOptOut(t *testing.T) {
+	a := assert.New(t)
+
+	// Disabled suggestions accept the arguments.
+	ctx := buildTestContext()
+	DisableSuggestions()
+	a.NoError(Parse(append(ctx.arguments, "file.txt"), ctx.flags))
+	a.Equal([]string{"file.txt"}, ctx.flags.Args())
+
+	// An unlimited argument range accepts the arguments.
+	ctx = buildTestContext()
+	command.SetArgRange(0, -1)
+	a.NoError(Parse(append(ctx.arguments, "file.txt"), ctx.flags))
+	a.Equal([]string{"file.txt"}, ctx.flags.Args())
+}
+
+func TestMultiCall(t *testing.T) {
+	a := assert.New(t)
+
+	tests := []struct {
+		executable string
+		args       []string
+		wantWorld  bool
+		wantFoo    bool
+	}{
+		{"/usr/bin/app-world", []string{"--test3", "13"}, true, false},
+		{"/usr/bin/world", []string{"--test3", "13"}, true, false},
+		{"/usr/bin/app", []string{"foo"}, false, true},
+	}
+
+	for _, test := range tests {
+		ctx := buildTestContext()
+		SetMultiCall()
+		a.NoError(ctx.cmdWorld.SetAliases("app-world"))
+
+		// Run cflag parser with the executable name as first argument.
+		arguments := append([]string{test.executable}, test.args...)
+		a.Nil(Parse(arguments, ctx.flags), test.executable)
+
+		// Check command state.
+		a.Equal(test.wantWorld, ctx.cmdWorld.IsActive(), test.executable)
+		a.Equal(test.wantFoo, ctx.cmdFoo.IsActive(), test.executable)
+		if test.wantWorld {
+			a.Equal(13, *ctx.paramTest3)
+		}
+	}
+}
+
+func TestResetState(t *testing.T) {
+	a := assert.New(t)
+	Reset()
+
+	// Define flags with non-empty defaults.
+	flags := NewFlagSet("", flag.ContinueOnError)
+	paramSlice := flags.StringSlice("slice", []string{"a", "b"}, "Slice flag.")
+	paramMap := flags.StringToString("map", map[string]string{"k": "v"}, "Map flag.")
+	paramIP := flags.IP("ip", nil, "IP flag.")
+	paramInt := flags.Int("int", 5, "Int flag.")
+	cmd := NewCommand("", "", flags)
+	cmd.SetNoExit()
+
+	// Parse twice with resetting the state.
+	a.NoError(cmd.Parse([]string{"app", "--slice", "c", "--int", "6"}))
+	a.Equal([]string{"c"}, *paramSlice)
+	a.NoError(cmd.ResetState())
+	a.False(cmd.IsActive())
+	a.False(flags.Changed("slice"))
+	a.False(flags.Changed("int"))
+	a.Equal([]string{"a", "b"}, *paramSlice)
+	a.Equal(map[string]string{"k": "v"}, *paramMap)
+	a.Equal(net.IP(nil), *paramIP)
+	a.Equal(5, *paramInt)
+	a.NoError(cmd.Parse([]string{"app", "--slice", "d", "--slice", "e"}))
+	a.Equal([]string{"d", "e"}, *paramSlice)
+	a.Equal(5, *paramInt)
+
+	// The internal annotation is not exported.
+	a.NoError(cmd.ResetState())
+	data, err := cmd.MarshalSpec()
+	a.NoError(err)
+	a.NotContains(string(data), resetAnnotation)
+
+	// Values set from the environment replace the default elements.
+	a.NoError(cmd.BindFlagEnv("slice", "RESET_SLICE"))
+	t.Setenv("RESET_SLICE", "f")
+	a.NoError(cmd.Parse([]string{"app"}))
+	a.Equal([]string{"f"}, *paramSlice)
+
+	// Map values and unset IP values are restored.
+	a.NoError(cmd.Parse([]string{"app", "--map", "x=y", "--map", "k=w", "--ip", "1.2.3.4"}))
+	a.Equal(map[string]string{"x": "y", "k": "w"}, *paramMap)
+	a.NoError(cmd.ResetState())
+	a.Equal(map[string]string{"k": "v"}, *paramMap)
+	a.Equal(net.IP(nil), *paramIP)
+	a.NoError(cmd.Parse([]string{"app", "--map", "a=b"}))
+	a.Equal(map[string]string{"a": "b"}, *paramMap)
+	a.Equal(net.IP(nil), *paramIP)
+
+	// Maps with an empty default cannot be cleared.
+	paramEmpty := flags.StringToInt("empty", nil, "Empty map flag.")
+	a.NoError(cmd.ResetState())
+	a.NoError(cmd.Parse([]string{"app", "--empty", "a=1"}))
+	a.Equal(map[string]int{"a": 1}, *paramEmpty)
+	err = cmd.ResetState()
+	t.Log(err)
+	a.ErrorContains(err, "cannot reset flag 'empty'")
+	var parseErr *FlagParseError
+	a.False(errors.As(err, &parseErr))
+}
+
+func TestResetStateReuse(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Parse the same command tree repeatedly.
+	tests := []struct {
+		args      []string
+		wantFoo   bool
+		wantBar   bool
+		wantWorld bool
+		wantTest1 int
+	}{
+		{[]string{"foo", "--test1", "11", "bar"}, true, true, false, 11},
+		{[]string{"world"}, false, false, true, 1},
+		{[]string{"foo"}, true, false, false, 1},
+	}
+	for _, test := range tests {
+		a.NoError(ResetState())
+		a.Nil(Parse(append(ctx.arguments, test.args...), ctx.flags), test.args)
+		a.Equal(test.wantFoo, ctx.cmdFoo.IsActive(), test.args)
+		a.Equal(test.wantBar, ctx.cmdFooBar.IsActive(), test.args)
+		a.Equal(test.wantWorld, ctx.cmdWorld.IsActive(), test.args)
+		a.Equal(test.wantTest1, *ctx.paramTest1, test.args)
+		a.Equal(test.wantTest1 != 1, ctx.flagsFoo.Changed("test1"), test.args)
+	}
+}
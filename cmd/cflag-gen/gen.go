@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/forside/cflag"
+	"gopkg.in/yaml.v3"
+)
+
+// A goType describes the Go type of a pflag type and the
+// flag.FlagSet method returning the value of a flag of the type.
+type goType struct {
+	name   string
+	getter string
+	pkg    string
+	// nilable is set for types whose unset values are written as "<nil>",
+	// which the getter cannot convert.
+	nilable bool
+}
+
+// The Go types of all pflag types by pflag type name.
+var goTypes = map[string]goType{
+	"bool":           {"bool", "GetBool", "", false},
+	"string":         {"string", "GetString", "", false},
+	"int":            {"int", "GetInt", "", false},
+	"int8":           {"int8", "GetInt8", "", false},
+	"int16":          {"int16", "GetInt16", "", false},
+	"int32":          {"int32", "GetInt32", "", false},
+	"int64":          {"int64", "GetInt64", "", false},
+	"uint":           {"uint", "GetUint", "", false},
+	"uint8":          {"uint8", "GetUint8", "", false},
+	"uint16":         {"uint16", "GetUint16", "", false},
+	"uint32":         {"uint32", "GetUint32", "", false},
+	"uint64":         {"uint64", "GetUint64", "", false},
+	"float32":        {"float32", "GetFloat32", "", false},
+	"float64":        {"float64", "GetFloat64", "", false},
+	"count":          {"int", "GetCount", "", false},
+	"duration":       {"time.Duration", "GetDuration", "time", false},
+	"ip":             {"net.IP", "GetIP", "net", true},
+	"ipNet":          {"net.IPNet", "GetIPNet", "net", true},
+	"ipMask":         {"net.IPMask", "GetIPv4Mask", "net", true},
+	"bytesHex":       {"[]byte", "GetBytesHex", "", false},
+	"bytesBase64":    {"[]byte", "GetBytesBase64", "", false},
+	"stringSlice":    {"[]string", "GetStringSlice", "", false},
+	"stringArray":    {"[]string", "GetStringArray", "", false},
+	"boolSlice":      {"[]bool", "GetBoolSlice", "", false},
+	"intSlice":       {"[]int", "GetIntSlice", "", false},
+	"int32Slice":     {"[]int32", "GetInt32Slice", "", false},
+	"int64Slice":     {"[]int64", "GetInt64Slice", "", false},
+	"uintSlice":      {"[]uint", "GetUintSlice", "", false},
+	"float32Slice":   {"[]float32", "GetFloat32Slice", "", false},
+	"float64Slice":   {"[]float64", "GetFloat64Slice", "", false},
+	"durationSlice":  {"[]time.Duration", "GetDurationSlice", "time", false},
+	"ipSlice":        {"[]net.IP", "GetIPSlice", "net", false},
+	"stringToString": {"map[string]string", "GetStringToString", "", false},
+	"stringToInt":    {"map[string]int", "GetStringToInt", "", false},
+	"stringToInt64":  {"map[string]int64", "GetStringToInt64", "", false},
+}
+
+// A genCommand holds the information required
+// to generate the code for a single command.
+type genCommand struct {
+	spec *cflag.CommandSpec
+	// path is the command path below the top-level command, e.g. "foo bar".
+	path string
+	// prefix is the Go identifier prefix, e.g. "FooBar".
+	prefix string
+	// flags holds the own and the inherited flags.
+	flags []*cflag.FlagSpec
+}
+
+// generate returns the formatted Go source code in package pkg
+// for the JSON or YAML encoded specification data.
+func generate(data []byte, pkg string) ([]byte, error) {
+	// Check that the specification can be loaded.
+	if _, err := cflag.LoadSpec(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	var spec cflag.Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+
+	// Collect commands and check for identifier collisions.
+	commands := collectCommands(spec.Command, "", "", nil)
+	prefixes := make(map[string]string)
+	imports := map[string]bool{"context": true, "strings": true}
+	for _, cmd := range commands {
+		if other, ok := prefixes[cmd.prefix]; ok {
+			return nil, fmt.Errorf("commands %q and %q map to the same identifier %q", other, cmd.path, cmd.prefix)
+		}
+		prefixes[cmd.prefix] = cmd.path
+
+		fields := make(map[string]string)
+		for _, name := range cmd.fieldNames() {
+			field := goName(name)
+			if other, ok := fields[field]; ok {
+				return nil, fmt.Errorf("command %q: %q and %q map to the same field %q", cmd.path, other, name, field)
+			}
+			fields[field] = name
+		}
+		for _, f := range cmd.flags {
+			t, ok := goTypes[f.Type]
+			if !ok {
+				return nil, fmt.Errorf("command %q: flag %q: unsupported type %q", cmd.path, f.Name, f.Type)
+			}
+			if len(t.pkg) > 0 {
+				imports[t.pkg] = true
+			}
+		}
+	}
+
+	// Encode the specification for embedding.
+	specJSON, err := json.MarshalIndent(&spec, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	_, _ = fmt.Fprintf(buf, "// Code generated by cflag-gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	var stdImports []string
+	for imp := range imports {
+		stdImports = append(stdImports, imp)
+	}
+	sort.Strings(stdImports)
+	for _, imp := range stdImports {
+		_, _ = fmt.Fprintf(buf, "\t%q\n", imp)
+	}
+	buf.WriteString("\n\t\"github.com/forside/cflag\"\n\tflag \"github.com/spf13/pflag\"\n)\n\n")
+
+	// Add options, handlers and option getters.
+	for _, cmd := range commands {
+		cmd.writeTypes(buf)
+	}
+
+	// Add handler set and constructor.
+	buf.WriteString("// Handlers holds the handlers of the commands. Commands without a handler\n// execute the handler of the closest parent command with a handler, which\n// receives the options of its own command.\ntype Handlers struct {\n")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(buf, "\t%s %sHandler\n", cmd.prefix, cmd.prefix)
+	}
+	buf.WriteString("}\n\n")
+	buf.WriteString("// NewCommand builds the command tree and binds the handlers.\nfunc NewCommand(handlers Handlers) (*cflag.Command, error) {\n")
+	buf.WriteString("\troot, err := cflag.LoadSpec(strings.NewReader(spec))\n\tif err != nil {\n\t\treturn nil, err\n\t}\n\n")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(buf, "\tif handlers.%s != nil {\n", cmd.prefix)
+		_, _ = fmt.Fprintf(buf, "\t\thandler, cmd := handlers.%s, root.Find(%q)\n", cmd.prefix, cmd.path)
+		buf.WriteString("\t\tcmd.SetContextCallback(func(ctx context.Context, _ *cflag.Command, _ *flag.FlagSet) error {\n")
+		buf.WriteString("\t\t\t// Read the options from the command of the handler, which may be\n\t\t\t// a parent of the active command.\n")
+		_, _ = fmt.Fprintf(buf, "\t\t\topts, err := get%sOptions(cmd, cmd.GetFlags())\n\t\t\tif err != nil {\n\t\t\t\treturn err\n\t\t\t}\n", cmd.prefix)
+		buf.WriteString("\t\t\treturn handler.Run(ctx, opts)\n\t\t})\n\t}\n")
+	}
+	buf.WriteString("\n\treturn root, nil\n}\n\n")
+
+	// Add the embedded specification.
+	_, _ = fmt.Fprintf(buf, "// spec is the specification of the command tree.\nconst spec = %s\n", goString(string(specJSON)))
+
+	return format.Source(buf.Bytes())
+}
+
+// collectCommands returns the commands of the tree below spec in depth-first
+// order, where the inherited flags are the persistent flags of the parents.
+func collectCommands(spec *cflag.CommandSpec, path, prefix string, inherited []*cflag.FlagSpec) []*genCommand {
+	if len(path) == 0 {
+		prefix = "Root"
+	}
+	cmd := &genCommand{spec: spec, path: path, prefix: prefix}
+
+	// Own flags shadow inherited flags.
+	var persistent []*cflag.FlagSpec
+	for _, f := range inherited {
+		if !hasFlag(spec.Flags, f.Name) {
+			cmd.flags = append(cmd.flags, f)
+			persistent = append(persistent, f)
+		}
+	}
+	for _, f := range spec.Flags {
+		cmd.flags = append(cmd.flags, f)
+		if f.Persistent {
+			persistent = append(persistent, f)
+		}
+	}
+
+	commands := []*genCommand{cmd}
+	for _, subSpec := range spec.Commands {
+		subPrefix := goName(subSpec.Name)
+		if len(path) > 0 {
+			subPrefix = prefix + subPrefix
+		}
+		commands = append(commands, collectCommands(subSpec, strings.TrimSpace(path+" "+subSpec.Name), subPrefix, persistent)...)
+	}
+	return commands
+}
+
+// fieldNames returns the names of the flags and positional arguments
+// of the command, which are mapped to fields of the options struct.
+func (c *genCommand) fieldNames() []string {
+	var names []string
+	for _, f := range c.flags {
+		names = append(names, f.Name)
+	}
+	if len(c.spec.Args) == 0 {
+		return append(names, "args")
+	}
+	for _, arg := range c.spec.Args {
+		names = append(names, arg.Name)
+	}
+	return names
+}
+
+// writeTypes writes the options struct, the handler interface
+// and the function returning the options of the command to buf.
+func (c *genCommand) writeTypes(buf *bytes.Buffer) {
+	desc := "the top-level command"
+	if len(c.path) > 0 {
+		desc = fmt.Sprintf("the command %q", c.path)
+	}
+
+	// Add options struct.
+	_, _ = fmt.Fprintf(buf, "// %sOptions holds the flag and argument values of %s.\ntype %sOptions struct {\n", c.prefix, desc, c.prefix)
+	for _, f := range c.flags {
+		if len(f.Usage) > 0 {
+			_, _ = fmt.Fprintf(buf, "\t// %s\n", goComment(f.Usage))
+		}
+		_, _ = fmt.Fprintf(buf, "\t%s %s\n", goName(f.Name), goTypes[f.Type].name)
+	}
+	if len(c.spec.Args) == 0 {
+		buf.WriteString("\t// Args holds the positional arguments.\n\tArgs []string\n")
+	}
+	for _, arg := range c.spec.Args {
+		if len(arg.Usage) > 0 {
+			_, _ = fmt.Fprintf(buf, "\t// %s\n", goComment(arg.Usage))
+		}
+		if arg.Variadic {
+			_, _ = fmt.Fprintf(buf, "\t%s []string\n", goName(arg.Name))
+		} else {
+			_, _ = fmt.Fprintf(buf, "\t%s string\n", goName(arg.Name))
+		}
+	}
+	buf.WriteString("}\n\n")
+
+	// Add handler interface.
+	_, _ = fmt.Fprintf(buf, "// %sHandler handles %s.\ntype %sHandler interface {\n", c.prefix, desc, c.prefix)
+	buf.WriteString("\tRun(ctx context.Context, opts " + c.prefix + "Options) error\n}\n\n")
+
+	// Add options getter.
+	_, _ = fmt.Fprintf(buf, "// get%sOptions returns the options of %s.\n", c.prefix, desc)
+	_, _ = fmt.Fprintf(buf, "func get%sOptions(command *cflag.Command, flags *flag.FlagSet) (opts %sOptions, err error) {\n", c.prefix, c.prefix)
+	for _, f := range c.flags {
+		t := goTypes[f.Type]
+		get := fmt.Sprintf("if opts.%s, err = flags.%s(%q); err != nil {\n\t\treturn opts, err\n\t}", goName(f.Name), t.getter, f.Name)
+		if t.nilable {
+			// Keep the zero value for unset values.
+			get = fmt.Sprintf("if flags.Lookup(%q).Value.String() != \"<nil>\" {\n\t%s\n\t}", f.Name, strings.ReplaceAll(get, "\n", "\n\t"))
+		}
+		_, _ = fmt.Fprintf(buf, "\t%s\n", get)
+	}
+	if len(c.spec.Args) == 0 {
+		buf.WriteString("\topts.Args = flags.Args()\n")
+	}
+	for _, arg := range c.spec.Args {
+		if arg.Variadic {
+			_, _ = fmt.Fprintf(buf, "\topts.%s = command.GetArgValues(%q)\n", goName(arg.Name), arg.Name)
+		} else {
+			_, _ = fmt.Fprintf(buf, "\topts.%s = command.GetArg(%q)\n", goName(arg.Name), arg.Name)
+		}
+	}
+	buf.WriteString("\treturn opts, nil\n}\n\n")
+}
+
+// hasFlag reports whether flags contains a flag named name.
+func hasFlag(flags []*cflag.FlagSpec, name string) bool {
+	for _, f := range flags {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// goName converts a flag, argument or command name to an exported
+// Go identifier, e.g. "dry-run" to "DryRun".
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteString("X")
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
+
+// goComment returns s on a single line for use in a line comment.
+func goComment(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// goString returns s as a raw string literal,
+// or as an interpreted string literal if s contains backquotes.
+func goString(s string) string {
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
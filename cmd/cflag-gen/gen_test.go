@@ -0,0 +1,179 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSpec = `
+version: 1
+command:
+  name: ""
+  flags:
+    - {name: verbose, shorthand: V, type: bool, usage: Verbose output., persistent: true}
+  commands:
+    - name: foo
+      flags:
+        - {name: test1, type: int, default: "1", usage: Test 1.}
+      commands:
+        - name: bar
+          args:
+            - {name: files, usage: Input files., variadic: true, optional: true}
+          flags:
+            - {name: dry-run, type: bool, usage: Dry run.}
+            - {name: timeout, type: duration, default: 5s, usage: Timeout.}
+`
+
+func TestGenerate(t *testing.T) {
+	a := assert.New(t)
+
+	src, err := generate([]byte(testSpec), "cli")
+	a.NoError(err)
+	code := string(src)
+	t.Log(code)
+
+	// Check package, imports and embedded specification.
+	a.Contains(code, "// Code generated by cflag-gen. DO NOT EDIT.\n\npackage cli\n")
+	a.Contains(code, "\t\"time\"\n")
+	a.NotContains(code, "\t\"net\"\n")
+	a.Contains(code, "const spec = `{\n  \"version\": 1,")
+
+	// Check options with inherited flags and arguments.
+	a.Contains(code, "type FooBarOptions struct {\n\t// Verbose output.\n\tVerbose bool\n\t// Dry run.\n\tDryRun bool\n\t// Timeout.\n\tTimeout time.Duration\n\t// Input files.\n\tFiles []string\n}")
+	a.Contains(code, "type FooOptions struct {\n\t// Verbose output.\n\tVerbose bool\n\t// Test 1.\n\tTest1 int\n\t// Args holds the positional arguments.\n\tArgs []string\n}")
+	a.Contains(code, "if opts.Timeout, err = flags.GetDuration(\"timeout\"); err != nil {")
+	a.Contains(code, "opts.Files = command.GetArgValues(\"files\")")
+
+	// Check handlers.
+	a.Contains(code, "type FooBarHandler interface {\n\tRun(ctx context.Context, opts FooBarOptions) error\n}")
+	a.Contains(code, "type Handlers struct {\n\tRoot   RootHandler\n\tFoo    FooHandler\n\tFooBar FooBarHandler\n}")
+	a.Contains(code, "handler, cmd := handlers.FooBar, root.Find(\"foo bar\")")
+}
+
+// buildSpec is the specification of the command tree built by TestGenerateBuild.
+const buildSpec = `
+version: 1
+command:
+  name: ""
+  flags:
+    - {name: verbose, shorthand: V, type: bool, persistent: true}
+  commands:
+    - name: foo
+      flags:
+        - {name: test1, type: int, default: "1"}
+        - {name: addr, type: ip}
+        - {name: mask, type: ipMask}
+      commands:
+        - name: bar
+          flags:
+            - {name: net, type: ipNet}
+`
+
+// buildMain is the main package of the application built by TestGenerateBuild.
+// It only sets a handler for the command "foo".
+const buildMain = `package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gentest/cli"
+)
+
+type fooHandler struct{}
+
+func (fooHandler) Run(ctx context.Context, opts cli.FooOptions) error {
+	fmt.Printf("%t %d %v %v %v", opts.Verbose, opts.Test1, opts.Addr, opts.Mask, opts.Args)
+	return nil
+}
+
+func main() {
+	cmd, err := cli.NewCommand(cli.Handlers{Foo: fooHandler{}})
+	if err == nil {
+		err = cmd.SetNoExit().Parse(os.Args)
+	}
+	if err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+}
+`
+
+func TestGenerateBuild(t *testing.T) {
+	a := assert.New(t)
+	if testing.Short() {
+		t.Skip("skipping build in short mode")
+	}
+
+	// Write the generated code into a module using this checkout of cflag.
+	root, err := filepath.Abs("../..")
+	a.NoError(err)
+	src, err := generate([]byte(buildSpec), "cli")
+	if !a.NoError(err) {
+		return
+	}
+	dir := t.TempDir()
+	goMod := "module gentest\n\ngo 1.21\n\nrequire github.com/forside/cflag v0.0.0\n\nreplace github.com/forside/cflag => " + root + "\n"
+	goSum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	a.NoError(err)
+	a.NoError(os.Mkdir(filepath.Join(dir, "cli"), 0o755))
+	a.NoError(os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644))
+	a.NoError(os.WriteFile(filepath.Join(dir, "go.sum"), goSum, 0o644))
+	a.NoError(os.WriteFile(filepath.Join(dir, "cli", "cli.go"), src, 0o644))
+	a.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte(buildMain), 0o644))
+
+	// Build the application.
+	build := exec.Command("go", "build", "-o", "app", ".")
+	build.Dir = dir
+	build.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	output, err := build.CombinedOutput()
+	if !a.NoError(err, string(output)) {
+		return
+	}
+
+	// The handler of "foo" runs for "foo bar" with the options of "foo".
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"foo", "bar", "-V"}, "true 1 <nil> <nil> []"},
+		{[]string{"foo", "--test1", "5", "--addr", "10.0.0.1", "--mask", "255.255.255.0", "bar", "--net", "10.0.0.0/8"}, "false 5 10.0.0.1 ffffff00 []"},
+	}
+	for _, test := range tests {
+		output, err := exec.Command(filepath.Join(dir, "app"), test.args...).CombinedOutput()
+		a.NoError(err, test.args)
+		a.Equal(test.want, string(output), test.args)
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	a := assert.New(t)
+
+	tests := []string{
+		// Invalid specification.
+		`{"version": 1, "command": {"name": "", "flags": [{"name": "a", "type": "complex"}]}}`,
+		// Colliding field names.
+		`{"version": 1, "command": {"name": "", "flags": [{"name": "dry-run", "type": "bool"}, {"name": "dry_run", "type": "bool"}]}}`,
+		// Colliding type names.
+		`{"version": 1, "command": {"name": "", "commands": [{"name": "foo-bar"}, {"name": "foo", "commands": [{"name": "bar"}]}]}}`,
+	}
+	for _, test := range tests {
+		_, err := generate([]byte(test), "cli")
+		t.Log(err)
+		a.Error(err, test)
+	}
+}
+
+func TestGoName(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal("DryRun", goName("dry-run"))
+	a.Equal("IPv6Only", goName("iPv6_only"))
+	a.Equal("X2fa", goName("2fa"))
+	a.Equal("X", goName("--"))
+}
@@ -0,0 +1,64 @@
+// Command cflag-gen generates Go code from a cflag command tree specification.
+//
+// The generated code builds the command tree using cflag.LoadSpec and provides
+// a typed options struct and a handler interface for each command, so that
+// renaming a flag in the specification causes a compile error in the code
+// using it instead of a runtime error.
+//
+// Usage:
+//
+//	cflag-gen --spec cli.yaml --package cli --output cli_gen.go
+//
+// The specification is written in the JSON or YAML format of cflag.Command.MarshalSpec.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/forside/cflag"
+	flag "github.com/spf13/pflag"
+)
+
+func main() {
+	// Define flags.
+	flags := cflag.NewFlagSet("", flag.ExitOnError)
+	flags.SortFlags = false
+	paramSpec := flags.StringP("spec", "s", "", "Path of the specification file.")
+	paramPackage := flags.StringP("package", "p", "main", "Package name of the generated code.")
+	paramOutput := flags.StringP("output", "o", "", "Path of the generated file (default standard output).")
+	root := cflag.NewCommand("", "", flags)
+	root.SetDescription("Generates typed options and handlers from a cflag specification.")
+	if err := root.MarkFlagRequired("spec"); err != nil {
+		panic(err)
+	}
+
+	// Parse arguments and generate code.
+	root.SetCallback(func(command *cflag.Command, flags *flag.FlagSet) error {
+		return run(*paramSpec, *paramPackage, *paramOutput)
+	})
+	if err := root.Parse(os.Args); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run generates the code for the specification at specPath
+// and writes it to output, or to os.Stdout if output is empty.
+func run(specPath, pkg, output string) error {
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+
+	src, err := generate(data, pkg)
+	if err != nil {
+		return err
+	}
+
+	if len(output) == 0 {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(output, src, 0o644)
+}
@@ -0,0 +1,1098 @@
+package cflag
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// A CompletionFunc returns the candidates for the word toComplete, which is
+// the value of a flag or a positional argument of command. args holds the
+// positional arguments supplied to command so far. The flags supplied
+// before the word are parsed and can be read from the flag set of command.
+type CompletionFunc func(command *Command, args []string, toComplete string) ([]Completion, CompletionDirective)
+
+// A Completion is a candidate returned by a CompletionFunc.
+type Completion struct {
+	// Value is the candidate inserted by the shell.
+	Value string
+	// Description is displayed next to the candidate by shells supporting it.
+	Description string
+}
+
+// A CompletionDirective instructs the shell how to handle the candidates
+// returned by a CompletionFunc. Directives can be combined.
+type CompletionDirective int
+
+const (
+	// CompletionDefault uses the candidates and falls back
+	// to file completion when there are none.
+	CompletionDefault CompletionDirective = 0
+	// CompletionError indicates that no completion is possible.
+	CompletionError CompletionDirective = 1
+	// CompletionNoSpace prevents the shell from adding a space after the candidate.
+	CompletionNoSpace CompletionDirective = 2
+	// CompletionNoFileComp prevents the fallback to file completion.
+	CompletionNoFileComp CompletionDirective = 4
+	// CompletionFilterFileExt completes files with one of the extensions
+	// returned as candidates, e.g. "json" or "yaml".
+	CompletionFilterFileExt CompletionDirective = 8
+	// CompletionFilterDirs completes directory names only.
+	CompletionFilterDirs CompletionDirective = 16
+)
+
+// completionNode holds the information required to complete
+// the subcommands and flags of a single command.
+type completionNode struct {
+	path         string
+	commands     []*Command
+	flags        []*flag.Flag
+	dynamicFlags []*flag.Flag
+	dynamicArgs  bool
+}
+
+// The name of the built-in completion command. See Command.AddCompletionCommand.
+const completionCommandName = "completion"
+
+// The name of the hidden argument requesting dynamic completions. See Command.Parse.
+const completeCommandName = "__complete"
+
+// Matches all characters which are not allowed in shell function names.
+var invalidFunctionChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// GenBashCompletion writes a bash completion script for the command tree
+// of the top-level command of c to w.
+// Hidden and deprecated commands and flags are left out.
+func (c *Command) GenBashCompletion(w io.Writer) error {
+	root := c.root()
+	prog := root.GetCommandPath()
+	fn := completionFunctionName(prog)
+	nodes := completionNodes(root)
+	buf := new(bytes.Buffer)
+
+	_, _ = fmt.Fprintf(buf, "# bash completion for %s\n\n", prog)
+	writeShCompletionHelpers(buf, fn, nodes)
+
+	// Add functions listing the subcommands and flags of a command.
+	_, _ = fmt.Fprintf(buf, "%s_commands()\n{\n    case \"$1\" in\n", fn)
+	for _, node := range nodes {
+		var words []string
+		for _, cmd := range node.commands {
+			words = append(words, cmd.names()...)
+		}
+		_, _ = fmt.Fprintf(buf, "        %s) echo %s ;;\n", shQuote(node.path), shQuote(strings.Join(words, " ")))
+	}
+	_, _ = fmt.Fprintf(buf, "    esac\n}\n\n")
+	_, _ = fmt.Fprintf(buf, "%s_flags()\n{\n    case \"$1\" in\n", fn)
+	for _, node := range nodes {
+		var words []string
+		for _, f := range node.flags {
+			words = append(words, flagNames(f)...)
+		}
+		_, _ = fmt.Fprintf(buf, "        %s) echo %s ;;\n", shQuote(node.path), shQuote(strings.Join(words, " ")))
+	}
+	_, _ = fmt.Fprintf(buf, "    esac\n}\n\n")
+
+	// Add function calling the application for dynamic completions.
+	_, _ = fmt.Fprintf(buf, `%[1]s_dynamic()
+{
+    local cur out directive line ext
+    local -a values
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    out="$("${COMP_WORDS[0]}" %[2]s "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)" || return
+    directive="${out##*:}"
+    out="${out%%:*}"
+    while IFS='' read -r line; do
+        if [[ -n "$line" ]]; then
+            values+=( "${line%%%%$'\t'*}" )
+        fi
+    done <<< "$out"
+
+    if (( directive & %[3]d )); then
+        return
+    fi
+    if (( directive & %[4]d )); then
+        compopt -o nospace 2>/dev/null
+    fi
+    if (( directive & %[5]d )); then
+        compopt +o default 2>/dev/null
+    fi
+    if (( directive & %[6]d )); then
+        for ext in "${values[@]}"; do
+            COMPREPLY+=( $(compgen -f -X "!*.${ext}" -- "$cur") )
+        done
+        COMPREPLY+=( $(compgen -d -- "$cur") )
+    elif (( directive & %[7]d )); then
+        COMPREPLY+=( $(compgen -d -- "$cur") )
+    else
+        for line in "${values[@]}"; do
+            if [[ "$line" == "$cur"* ]]; then
+                COMPREPLY+=( "$line" )
+            fi
+        done
+    fi
+}
+
+`, fn, completeCommandName, CompletionError, CompletionNoSpace, CompletionNoFileComp, CompletionFilterFileExt, CompletionFilterDirs)
+
+	// Add main completion function.
+	_, _ = fmt.Fprintf(buf, `%[1]s()
+{
+    local cur cmd word next i
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    cmd=%[2]s
+    COMPREPLY=()
+
+    # Resolve the active command, skipping flag values.
+    for (( i = 1; i < COMP_CWORD; i++ )); do
+        word="${COMP_WORDS[i]}"
+        case "$word" in
+            --)
+                # All remaining arguments are positional.
+                if %[1]s_dynamic_args "$cmd"; then
+                    %[1]s_dynamic
+                fi
+                return
+                ;;
+            --*=*)
+                ;;
+            -*)
+                if %[1]s_takes_value "$cmd" "$word"; then
+                    (( i++ ))
+                fi
+                ;;
+            *)
+                next="$(%[1]s_subcommand "$cmd" "$word")"
+                if [[ -n "$next" ]]; then
+                    cmd="$next"
+                fi
+                ;;
+        esac
+    done
+
+    # Use the dynamic or default completion for flag values.
+    if (( COMP_CWORD > 1 )) && %[1]s_takes_value "$cmd" "${COMP_WORDS[COMP_CWORD-1]}"; then
+        if %[1]s_dynamic_value "$cmd" "${COMP_WORDS[COMP_CWORD-1]}"; then
+            %[1]s_dynamic
+        fi
+        return
+    fi
+
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=( $(compgen -W "$(%[1]s_flags "$cmd")" -- "$cur") )
+    else
+        COMPREPLY=( $(compgen -W "$(%[1]s_commands "$cmd")" -- "$cur") )
+        if %[1]s_dynamic_args "$cmd"; then
+            %[1]s_dynamic
+        fi
+    fi
+}
+
+complete -o default -F %[1]s %[3]s
+`, fn, shQuote(prog), prog)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// GenZshCompletion writes a zsh completion script for the command tree
+// of the top-level command of c to w.
+// Hidden and deprecated commands and flags are left out.
+func (c *Command) GenZshCompletion(w io.Writer) error {
+	root := c.root()
+	prog := root.GetCommandPath()
+	fn := completionFunctionName(prog)
+	nodes := completionNodes(root)
+	buf := new(bytes.Buffer)
+
+	_, _ = fmt.Fprintf(buf, "#compdef %s\n\n# zsh completion for %s\n\n", prog, prog)
+	writeShCompletionHelpers(buf, fn, nodes)
+
+	// Add functions setting the described subcommands and flags of a command.
+	_, _ = fmt.Fprintf(buf, "%s_commands()\n{\n    case \"$1\" in\n", fn)
+	for _, node := range nodes {
+		var candidates []string
+		for _, cmd := range node.commands {
+			for _, name := range cmd.names() {
+				candidates = append(candidates, shQuote(zshDescribe(name, cmd.usage)))
+			}
+		}
+		_, _ = fmt.Fprintf(buf, "        %s) candidates=( %s ) ;;\n", shQuote(node.path), strings.Join(candidates, " "))
+	}
+	_, _ = fmt.Fprintf(buf, "    esac\n}\n\n")
+	_, _ = fmt.Fprintf(buf, "%s_flags()\n{\n    case \"$1\" in\n", fn)
+	for _, node := range nodes {
+		var candidates []string
+		for _, f := range node.flags {
+			for _, name := range flagNames(f) {
+				candidates = append(candidates, shQuote(zshDescribe(name, f.Usage)))
+			}
+		}
+		_, _ = fmt.Fprintf(buf, "        %s) candidates=( %s ) ;;\n", shQuote(node.path), strings.Join(candidates, " "))
+	}
+	_, _ = fmt.Fprintf(buf, "    esac\n}\n\n")
+
+	// Add function calling the application for dynamic completions.
+	_, _ = fmt.Fprintf(buf, `%[1]s_dynamic()
+{
+    local out directive line value description
+    local -a completions values
+    out="$("${words[1]}" %[2]s "${(@)words[2,CURRENT]}" 2>/dev/null)" || return 1
+    directive="${out##*:}"
+    out="${out%%:*}"
+    for line in "${(@f)out}"; do
+        [[ -z "$line" ]] && continue
+        value="${line%%%%$'\t'*}"
+        description=""
+        if [[ "$line" == *$'\t'* ]]; then
+            description="${line#*$'\t'}"
+        fi
+        values+=( "$value" )
+        completions+=( "${value//:/\\:}:$description" )
+    done
+
+    if (( directive & %[3]d )); then
+        return 1
+    elif (( directive & %[6]d )); then
+        _files -g "*.(${(j:|:)values})"
+    elif (( directive & %[7]d )); then
+        _files -/
+    elif (( ${#completions} > 0 )); then
+        if (( directive & %[4]d )); then
+            _describe -t values 'value' completions -S ''
+        else
+            _describe -t values 'value' completions
+        fi
+    elif (( ! (directive & %[5]d) )); then
+        _files
+    fi
+}
+
+`, fn, completeCommandName, CompletionError, CompletionNoSpace, CompletionNoFileComp, CompletionFilterFileExt, CompletionFilterDirs)
+
+	// Add main completion function.
+	_, _ = fmt.Fprintf(buf, `%[1]s()
+{
+    local cmd word next i
+    local -a candidates
+    cmd=%[2]s
+
+    # Resolve the active command, skipping flag values.
+    for (( i = 2; i < CURRENT; i++ )); do
+        word="${words[i]}"
+        case "$word" in
+            --)
+                # All remaining arguments are positional.
+                if %[1]s_dynamic_args "$cmd"; then
+                    %[1]s_dynamic
+                else
+                    _files
+                fi
+                return
+                ;;
+            --*=*)
+                ;;
+            -*)
+                if %[1]s_takes_value "$cmd" "$word"; then
+                    (( i++ ))
+                fi
+                ;;
+            *)
+                next="$(%[1]s_subcommand "$cmd" "$word")"
+                if [[ -n "$next" ]]; then
+                    cmd="$next"
+                fi
+                ;;
+        esac
+    done
+
+    # Use dynamic or file completion for flag values.
+    if (( CURRENT > 2 )) && %[1]s_takes_value "$cmd" "${words[CURRENT-1]}"; then
+        if %[1]s_dynamic_value "$cmd" "${words[CURRENT-1]}"; then
+            %[1]s_dynamic
+        else
+            _files
+        fi
+        return
+    fi
+
+    if [[ "${words[CURRENT]}" == -* ]]; then
+        %[1]s_flags "$cmd"
+        _describe -t flags 'flag' candidates
+    elif %[1]s_dynamic_args "$cmd"; then
+        %[1]s_commands "$cmd"
+        _describe -t commands 'command' candidates
+        %[1]s_dynamic
+    else
+        %[1]s_commands "$cmd"
+        _describe -t commands 'command' candidates || _files
+    fi
+}
+
+compdef %[1]s %[3]s
+`, fn, shQuote(prog), prog)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// GenFishCompletion writes a fish completion script for the command tree
+// of the top-level command of c to w.
+// Hidden and deprecated commands and flags are left out.
+func (c *Command) GenFishCompletion(w io.Writer) error {
+	root := c.root()
+	prog := root.GetCommandPath()
+	fn := completionFunctionName(prog)
+	nodes := completionNodes(root)
+	buf := new(bytes.Buffer)
+
+	_, _ = fmt.Fprintf(buf, "# fish completion for %s\n\n", prog)
+
+	// Add function resolving subcommand names and aliases.
+	_, _ = fmt.Fprintf(buf, "function %s_subcommand\n    switch \"$argv[1]|$argv[2]\"\n", fn)
+	for _, node := range nodes {
+		for _, cmd := range node.commands {
+			var cases []string
+			for _, name := range cmd.names() {
+				cases = append(cases, fishQuote(node.path+"|"+name))
+			}
+			_, _ = fmt.Fprintf(buf, "        case %s\n            echo %s\n", strings.Join(cases, " "), fishQuote(node.path+" "+cmd.name))
+		}
+	}
+	_, _ = fmt.Fprintf(buf, "    end\nend\n\n")
+
+	// Add function reporting whether a flag takes a value.
+	_, _ = fmt.Fprintf(buf, "function %s_takes_value\n    switch \"$argv[1]|$argv[2]\"\n", fn)
+	for _, node := range nodes {
+		if cases := takesValueCases(node, fishQuote); len(cases) > 0 {
+			_, _ = fmt.Fprintf(buf, "        case %s\n            return 0\n", strings.Join(cases, " "))
+		}
+	}
+	_, _ = fmt.Fprintf(buf, "    end\n    return 1\nend\n\n")
+
+	// Add functions resolving the active command.
+	_, _ = fmt.Fprintf(buf, `function %[1]s_command_path
+    set -l tokens (commandline -opc)
+    set -l cmd %[2]s
+    set -l skip 0
+    set -e tokens[1]
+    for word in $tokens
+        if test $skip -eq 1
+            set skip 0
+            continue
+        end
+        switch $word
+            case '--'
+                # All remaining arguments are positional.
+                return
+            case '--*=*'
+            case '-*'
+                if %[1]s_takes_value $cmd $word
+                    set skip 1
+                end
+            case '*'
+                set -l next (%[1]s_subcommand $cmd $word)
+                if test -n "$next"
+                    set cmd $next
+                end
+        end
+    end
+    echo $cmd
+end
+
+function %[1]s_using_command
+    test (%[1]s_command_path) = "$argv[1]"
+end
+
+function %[1]s_dynamic
+    set -l args (commandline -opc)
+    set -l out (command $args[1] %[3]s $args[2..-1] (commandline -ct) 2>/dev/null)
+    or return
+    test (count $out) -gt 0
+    or return
+    set -l directive (string replace ':' '' -- $out[-1])
+    set -e out[-1]
+    if test (math "floor($directive / %[4]d) %% 2") -eq 1
+        return
+    end
+    if test (math "floor($directive / %[5]d) %% 2") -eq 1
+        for ext in $out
+            __fish_complete_suffix .$ext
+        end
+        __fish_complete_directories (commandline -ct) ''
+        return
+    end
+    if test (math "floor($directive / %[6]d) %% 2") -eq 1
+        __fish_complete_directories (commandline -ct) ''
+        return
+    end
+    printf '%%s\n' $out
+    if test (count $out) -eq 0; and test (math "floor($directive / %[7]d) %% 2") -eq 0
+        __fish_complete_path (commandline -ct)
+    end
+end
+
+`, fn, fishQuote(prog), completeCommandName, CompletionError, CompletionFilterFileExt, CompletionFilterDirs, CompletionNoFileComp)
+
+	// Add completions for subcommands, positional arguments and flags.
+	dynamic := fishQuote("(" + fn + "_dynamic)")
+	for _, node := range nodes {
+		condition := fishQuote(fn + "_using_command " + fishQuote(node.path))
+		for _, cmd := range node.commands {
+			for _, name := range cmd.names() {
+				_, _ = fmt.Fprintf(buf, "complete -c %s -n %s -f -a %s -d %s\n", prog, condition, fishQuote(name), fishQuote(cmd.usage))
+			}
+		}
+		if node.dynamicArgs {
+			_, _ = fmt.Fprintf(buf, "complete -c %s -n %s -f -a %s\n", prog, condition, dynamic)
+		}
+		for _, f := range node.flags {
+			line := fmt.Sprintf("complete -c %s -n %s -l %s", prog, condition, fishQuote(f.Name))
+			if len(f.Shorthand) > 0 && len(f.ShorthandDeprecated) == 0 {
+				line += " -s " + fishQuote(f.Shorthand)
+			}
+			if len(f.NoOptDefVal) == 0 {
+				line += " -r"
+			}
+			if slices.Contains(node.dynamicFlags, f) {
+				line += " -f -a " + dynamic
+			}
+			_, _ = fmt.Fprintf(buf, "%s -d %s\n", line, fishQuote(f.Usage))
+		}
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// GenPowerShellCompletion writes a PowerShell completion script for the command tree
+// of the top-level command of c to w.
+// Hidden and deprecated commands and flags are left out.
+func (c *Command) GenPowerShellCompletion(w io.Writer) error {
+	root := c.root()
+	prog := root.GetCommandPath()
+	nodes := completionNodes(root)
+	buf := new(bytes.Buffer)
+
+	_, _ = fmt.Fprintf(buf, "# powershell completion for %s\n\n", prog)
+	_, _ = fmt.Fprintf(buf, "Register-ArgumentCompleter -Native -CommandName %s -ScriptBlock {\n", psQuote(prog))
+	_, _ = fmt.Fprintf(buf, "    param($WordToComplete, $CommandAst, $CursorPosition)\n\n")
+
+	// Add tables holding subcommands and flags of each command.
+	_, _ = fmt.Fprintf(buf, "    $subcommands = @{\n")
+	for _, node := range nodes {
+		var entries []string
+		for _, cmd := range node.commands {
+			for _, name := range cmd.names() {
+				entries = append(entries, fmt.Sprintf("%s = %s", psQuote(name), psQuote(node.path+" "+cmd.name)))
+			}
+		}
+		_, _ = fmt.Fprintf(buf, "        %s = @{ %s }\n", psQuote(node.path), strings.Join(entries, "; "))
+	}
+	_, _ = fmt.Fprintf(buf, "    }\n    $candidates = @{\n")
+	for _, node := range nodes {
+		var entries []string
+		for _, cmd := range node.commands {
+			for _, name := range cmd.names() {
+				entries = append(entries, fmt.Sprintf("@(%s, %s)", psQuote(name), psQuote(cmd.usage)))
+			}
+		}
+		for _, f := range node.flags {
+			for _, name := range flagNames(f) {
+				entries = append(entries, fmt.Sprintf("@(%s, %s)", psQuote(name), psQuote(f.Usage)))
+			}
+		}
+		_, _ = fmt.Fprintf(buf, "        %s = @(%s)\n", psQuote(node.path), strings.Join(entries, ", "))
+	}
+	_, _ = fmt.Fprintf(buf, "    }\n    $valueFlags = @{\n")
+	for _, node := range nodes {
+		var entries []string
+		for _, f := range node.flags {
+			if len(f.NoOptDefVal) == 0 {
+				for _, name := range flagNames(f) {
+					entries = append(entries, psQuote(name))
+				}
+			}
+		}
+		_, _ = fmt.Fprintf(buf, "        %s = @(%s)\n", psQuote(node.path), strings.Join(entries, ", "))
+	}
+	_, _ = fmt.Fprintf(buf, "    }\n    $dynamicValueFlags = @{\n")
+	for _, node := range nodes {
+		var entries []string
+		for _, f := range node.dynamicFlags {
+			for _, name := range flagNames(f) {
+				entries = append(entries, psQuote(name))
+			}
+		}
+		_, _ = fmt.Fprintf(buf, "        %s = @(%s)\n", psQuote(node.path), strings.Join(entries, ", "))
+	}
+	var dynamicArgs []string
+	for _, node := range nodes {
+		if node.dynamicArgs {
+			dynamicArgs = append(dynamicArgs, psQuote(node.path))
+		}
+	}
+	_, _ = fmt.Fprintf(buf, "    }\n    $dynamicArgs = @(%s)\n", strings.Join(dynamicArgs, ", "))
+
+	// Add command resolution and candidate filtering.
+	_, _ = fmt.Fprintf(buf, `
+    # Calls the application for dynamic completions.
+    function Get-DynamicCompletion {
+        $arguments = @($words)
+        if ($WordToComplete -eq '' -and $PSVersionTable.PSVersion -lt [version]'7.3') {
+            $arguments += '""'
+        } else {
+            $arguments += $WordToComplete
+        }
+        $out = @(& $CommandAst.CommandElements[0].ToString() %[2]s @arguments 2>$null)
+        if ($out.Count -eq 0) {
+            return
+        }
+        $directive = [int]$out[-1].TrimStart(':')
+        $values = @($out | Select-Object -SkipLast 1)
+        if (($directive -band %[3]d) -ne 0) {
+            return
+        }
+        if (($directive -band %[5]d) -ne 0) {
+            Get-ChildItem -Path "$WordToComplete*" | Where-Object {
+                $_.PSIsContainer -or $values -contains $_.Extension.TrimStart('.')
+            } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_.Name, $_.Name, 'ProviderItem', $_.Name)
+            }
+            return
+        }
+        if (($directive -band %[6]d) -ne 0) {
+            Get-ChildItem -Path "$WordToComplete*" -Directory | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_.Name, $_.Name, 'ProviderContainer', $_.Name)
+            }
+            return
+        }
+        $results = @($values | ForEach-Object {
+            $value, $description = $_ -split "`+"`"+`t", 2
+            if ($value -like "$WordToComplete*") {
+                if (-not $description) {
+                    $description = $value
+                }
+                [System.Management.Automation.CompletionResult]::new($value, $value, 'ParameterValue', $description)
+            }
+        })
+        if ($results.Count -eq 0 -and ($directive -band %[4]d) -ne 0) {
+            return ''
+        }
+        $results
+    }
+
+    # Resolve the active command, skipping flag values.
+    $cmd = %[1]s
+    $skip = $false
+    $terminated = $false
+    $previous = ''
+    $words = @()
+    foreach ($element in ($CommandAst.CommandElements | Select-Object -Skip 1)) {
+        if ($element.Extent.EndOffset -ge $CursorPosition) {
+            break
+        }
+        $word = $element.ToString()
+        $words += $word
+        $previous = $word
+        if ($terminated) {
+            continue
+        }
+        if ($skip) {
+            $skip = $false
+            $previous = ''
+            continue
+        }
+        if ($word -eq '--') {
+            # All remaining arguments are positional.
+            $terminated = $true
+            continue
+        }
+        if ($word.StartsWith('-')) {
+            $skip = $valueFlags[$cmd] -contains $word
+            continue
+        }
+        if ($subcommands[$cmd].ContainsKey($word)) {
+            $cmd = $subcommands[$cmd][$word]
+        }
+    }
+
+    if ($terminated) {
+        if ($dynamicArgs -contains $cmd) {
+            Get-DynamicCompletion
+        }
+        return
+    }
+
+    # Use the dynamic or default completion for flag values.
+    if ($valueFlags[$cmd] -contains $previous) {
+        if ($dynamicValueFlags[$cmd] -contains $previous) {
+            Get-DynamicCompletion
+        }
+        return
+    }
+
+    $candidates[$cmd] | Where-Object {
+        $_[0] -like "$WordToComplete*" -and ($_[0].StartsWith('-') -eq $WordToComplete.StartsWith('-'))
+    } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_[0], $_[0], 'ParameterValue', $_[1])
+    }
+    if (-not $WordToComplete.StartsWith('-') -and $dynamicArgs -contains $cmd) {
+        Get-DynamicCompletion
+    }
+}
+`, psQuote(prog), completeCommandName, CompletionError, CompletionNoFileComp, CompletionFilterFileExt, CompletionFilterDirs)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// AddCompletionCommand adds the hidden built-in command "completion <shell>",
+// which writes the completion script for the given shell (bash, zsh, fish
+// or powershell) to os.Stdout. When the command is added successfully,
+// the Command value is returned. Else nil and an error is returned.
+func (c *Command) AddCompletionCommand() (*Command, error) {
+	cmd := NewCommand(completionCommandName, "Generate the shell completion script.", nil)
+	cmd.SetDescription("Supported shells are bash, zsh, fish and powershell.").MarkHidden()
+	if err := cmd.AddArg("shell", "Shell type."); err != nil {
+		return nil, err
+	}
+	cmd.SetCallback(func(command *Command, flags *flag.FlagSet) error {
+		switch shell := command.GetArg("shell"); shell {
+		case "bash":
+			return command.GenBashCompletion(os.Stdout)
+		case "zsh":
+			return command.GenZshCompletion(os.Stdout)
+		case "fish":
+			return command.GenFishCompletion(os.Stdout)
+		case "powershell":
+			return command.GenPowerShellCompletion(os.Stdout)
+		default:
+			return fmt.Errorf("unsupported shell '%s'", shell)
+		}
+	})
+
+	if err := c.AddCommand(cmd); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// RegisterFlagCompletionFunc registers f to complete the values of the flag name,
+// which must be defined in the flag set or the persistent flag set of the command.
+// Completion functions of persistent flags are inherited by subcommands.
+func (c *Command) RegisterFlagCompletionFunc(name string, f CompletionFunc) error {
+	if f == nil || len(name) == 0 {
+		return fmt.Errorf("invalid parameters")
+	}
+
+	// Check if the flag is defined for this command.
+	if (c.flags == nil || c.flags.Lookup(name) == nil) && (c.persistent == nil || c.persistent.Lookup(name) == nil) {
+		return fmt.Errorf("flag '%s' does not exist", name)
+	}
+
+	if c.flagComps == nil {
+		c.flagComps = make(map[string]CompletionFunc)
+	}
+	c.flagComps[name] = f
+	return nil
+}
+
+// RegisterArgCompletionFunc registers f to complete the values of the
+// declared positional argument name. See Command.AddArg.
+func (c *Command) RegisterArgCompletionFunc(name string, f CompletionFunc) error {
+	if f == nil || len(name) == 0 {
+		return fmt.Errorf("invalid parameters")
+	}
+
+	// Check if the argument is declared for this command.
+	if !slices.ContainsFunc(c.args, func(arg *Arg) bool {
+		return arg.Name == name
+	}) {
+		return fmt.Errorf("argument '%s' does not exist", name)
+	}
+
+	if c.argComps == nil {
+		c.argComps = make(map[string]CompletionFunc)
+	}
+	c.argComps[name] = f
+	return nil
+}
+
+// Complete resolves the active command for the partial command line arguments,
+// which do not include the application name, and returns the candidates for
+// the last argument. Commands are resolved like Parse does, and the flags
+// supplied to the active commands are parsed. The state of the command tree
+// is reset before Complete returns, see Command.ResetState.
+// The generated completion scripts call the application with the "__complete"
+// argument followed by the arguments to complete flag values and positional
+// arguments with a registered CompletionFunc.
+func (c *Command) Complete(arguments []string) ([]Completion, CompletionDirective) {
+	defer func() {
+		_ = c.ResetState()
+	}()
+
+	toComplete := ""
+	if len(arguments) > 0 {
+		toComplete = arguments[len(arguments)-1]
+		arguments = arguments[:len(arguments)-1]
+	}
+
+	// Resolve the active command, parsing the flags of each command on the way.
+	cmd := c
+	cmd.active = true
+	var cmdChain []*Command
+	for {
+		cmd.prepareFlags()
+		flagSets := []*flag.FlagSet{cmd.flags}
+		if cmd.recurseArgs {
+			for _, parentCmd := range cmdChain {
+				flagSets = append(flagSets, parentCmd.flags)
+			}
+		}
+		iArg := cmd.findSubCommand(arguments, flagSets)
+		if iArg < 0 {
+			break
+		}
+		_ = cmd.parseFlags(arguments[:iArg], true)
+		cmdChain = append(cmdChain, cmd)
+		cmd = cmd.matchCommand(arguments[iArg])
+		cmd.active = true
+		arguments = arguments[iArg+1:]
+	}
+
+	// Find a flag expecting the word as its value and the "--" terminator.
+	var pending *flag.Flag
+	terminated := false
+	for iArg := 0; iArg < len(arguments); iArg++ {
+		arg := arguments[iArg]
+		if arg == "--" {
+			terminated = true
+			break
+		}
+		if strings.HasPrefix(arg, "-") && len(arg) > 1 {
+			if f := valueFlag(arg, []*flag.FlagSet{cmd.flags}); f != nil {
+				if iArg == len(arguments)-1 {
+					pending = f
+					arguments = arguments[:iArg]
+				}
+				iArg++
+			}
+		}
+	}
+
+	// Parse the flags and positional arguments supplied to the active command.
+	_ = cmd.parseFlags(arguments, true)
+	cmd.argValues = cmd.flags.Args()
+
+	// Complete flag value.
+	if pending != nil {
+		if f := cmd.flagCompletionFunc(pending.Name); f != nil {
+			return f(cmd, cmd.argValues, toComplete)
+		}
+		return nil, CompletionDefault
+	}
+
+	// Complete flag names.
+	if !terminated && strings.HasPrefix(toComplete, "-") {
+		var completions []Completion
+		for _, f := range cmd.completionFlags() {
+			for _, name := range flagNames(f) {
+				if strings.HasPrefix(name, toComplete) {
+					completions = append(completions, Completion{Value: name, Description: f.Usage})
+				}
+			}
+		}
+		return completions, CompletionNoFileComp
+	}
+
+	// Complete subcommands and positional arguments.
+	var completions []Completion
+	directive := CompletionDefault
+	if !terminated {
+		for _, subCmd := range cmd.commands {
+			if subCmd.hidden {
+				continue
+			}
+			for _, name := range subCmd.names() {
+				if strings.HasPrefix(name, toComplete) {
+					completions = append(completions, Completion{Value: name, Description: subCmd.usage})
+				}
+			}
+		}
+	}
+	if arg := cmd.argAt(len(cmd.argValues)); arg != nil && cmd.argComps[arg.Name] != nil {
+		var argCompletions []Completion
+		argCompletions, directive = cmd.argComps[arg.Name](cmd, cmd.argValues, toComplete)
+		completions = append(completions, argCompletions...)
+	}
+
+	return completions, directive
+}
+
+// GenBashCompletion writes a bash completion script for the application to w.
+// See Command.GenBashCompletion.
+func GenBashCompletion(w io.Writer) error {
+	return command.GenBashCompletion(w)
+}
+
+// GenZshCompletion writes a zsh completion script for the application to w.
+// See Command.GenZshCompletion.
+func GenZshCompletion(w io.Writer) error {
+	return command.GenZshCompletion(w)
+}
+
+// GenFishCompletion writes a fish completion script for the application to w.
+// See Command.GenFishCompletion.
+func GenFishCompletion(w io.Writer) error {
+	return command.GenFishCompletion(w)
+}
+
+// GenPowerShellCompletion writes a PowerShell completion script for the application to w.
+// See Command.GenPowerShellCompletion.
+func GenPowerShellCompletion(w io.Writer) error {
+	return command.GenPowerShellCompletion(w)
+}
+
+// AddCompletionCommand adds the hidden built-in command "completion <shell>"
+// to the global register. See Command.AddCompletionCommand.
+func AddCompletionCommand() (*Command, error) {
+	return command.AddCompletionCommand()
+}
+
+// RegisterFlagCompletionFunc registers f to complete the values of the
+// top-level flag name. See Command.RegisterFlagCompletionFunc.
+func RegisterFlagCompletionFunc(name string, f CompletionFunc) error {
+	return command.RegisterFlagCompletionFunc(name, f)
+}
+
+// RegisterArgCompletionFunc registers f to complete the values of the declared
+// top-level positional argument name. See Command.RegisterArgCompletionFunc.
+func RegisterArgCompletionFunc(name string, f CompletionFunc) error {
+	return command.RegisterArgCompletionFunc(name, f)
+}
+
+// execComplete writes the completions for the arguments following the
+// "__complete" argument to os.Stdout, one "value<TAB>description" line per
+// candidate followed by a ":<directive>" line. In no-exit mode,
+// ErrCompletionRequested is returned. Else the application exits.
+func (c *Command) execComplete(arguments []string) error {
+	completions, directive := c.Complete(arguments[2:])
+	if err := writeCompletions(os.Stdout, completions, directive); err != nil {
+		return err
+	}
+	if c.isNoExit() {
+		return ErrCompletionRequested
+	}
+	os.Exit(0)
+	return nil
+}
+
+// flagCompletionFunc returns the completion function registered for the flag
+// name by the command or one of its parent commands, a function completing
+// the values set with SetFlagEnum, or nil if there is none.
+func (c *Command) flagCompletionFunc(name string) CompletionFunc {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if f := cmd.flagComps[name]; f != nil {
+			return f
+		}
+	}
+	return c.enumCompletionFunc(name)
+}
+
+// argAt returns the declared positional argument receiving
+// the value at index i, or nil if no argument receives it.
+func (c *Command) argAt(i int) *Arg {
+	for iArg, arg := range c.args {
+		if arg.Variadic || iArg == i {
+			return arg
+		}
+	}
+	return nil
+}
+
+// writeCompletions writes the candidates and the directive in the format
+// expected by the generated completion scripts to w.
+func writeCompletions(w io.Writer, completions []Completion, directive CompletionDirective) error {
+	buf := new(bytes.Buffer)
+	for _, completion := range completions {
+		if len(completion.Description) > 0 {
+			_, _ = fmt.Fprintf(buf, "%s\t%s\n", completion.Value, strings.ReplaceAll(completion.Description, "\n", " "))
+		} else {
+			_, _ = fmt.Fprintln(buf, completion.Value)
+		}
+	}
+	_, _ = fmt.Fprintf(buf, ":%d\n", directive)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// root returns the top-level command of the command tree c belongs to.
+func (c *Command) root() *Command {
+	root := c
+	for root.parent != nil {
+		root = root.parent
+	}
+	return root
+}
+
+// completionFlags returns the visible flags accepted by the command,
+// including inherited flags and the help flag.
+func (c *Command) completionFlags() []*flag.Flag {
+	flags := c.LocalFlags()
+	mergeFlags(flags, c.InheritedFlags())
+
+	// Add help flag if unset. It is only added to the flag set during parsing.
+	if flags.Lookup("help") == nil {
+		help := &flag.Flag{Name: "help", Usage: "Display help.", NoOptDefVal: "true"}
+		if flags.ShorthandLookup("h") == nil {
+			help.Shorthand = "h"
+		}
+		flags.AddFlag(help)
+	}
+
+	var res []*flag.Flag
+	flags.VisitAll(func(f *flag.Flag) {
+		if !f.Hidden && len(f.Deprecated) == 0 {
+			res = append(res, f)
+		}
+	})
+	return res
+}
+
+// completionNodes walks the visible command tree below root
+// and returns the completion information for each command.
+func completionNodes(root *Command) []*completionNode {
+	var nodes []*completionNode
+
+	var walk func(cmd *Command, path string)
+	walk = func(cmd *Command, path string) {
+		node := &completionNode{
+			path: path,
+			commands: filterSlice(cmd.commands, func(c *Command) bool {
+				return !c.hidden
+			}),
+			flags:       cmd.completionFlags(),
+			dynamicArgs: len(cmd.argComps) > 0,
+		}
+		node.dynamicFlags = filterSlice(node.flags, func(f *flag.Flag) bool {
+			return cmd.flagCompletionFunc(f.Name) != nil
+		})
+		nodes = append(nodes, node)
+		for _, subCmd := range node.commands {
+			walk(subCmd, path+" "+subCmd.name)
+		}
+	}
+	walk(root, root.GetCommandPath())
+
+	return nodes
+}
+
+// writeShCompletionHelpers writes the functions resolving subcommands
+// and flags taking a value, which are shared by bash and zsh.
+func writeShCompletionHelpers(buf *bytes.Buffer, fn string, nodes []*completionNode) {
+	_, _ = fmt.Fprintf(buf, "%s_subcommand()\n{\n    case \"$1|$2\" in\n", fn)
+	for _, node := range nodes {
+		for _, cmd := range node.commands {
+			var cases []string
+			for _, name := range cmd.names() {
+				cases = append(cases, shQuote(node.path+"|"+name))
+			}
+			_, _ = fmt.Fprintf(buf, "        %s) echo %s ;;\n", strings.Join(cases, "|"), shQuote(node.path+" "+cmd.name))
+		}
+	}
+	_, _ = fmt.Fprintf(buf, "    esac\n}\n\n")
+
+	_, _ = fmt.Fprintf(buf, "%s_takes_value()\n{\n    case \"$1|$2\" in\n", fn)
+	for _, node := range nodes {
+		if cases := takesValueCases(node, shQuote); len(cases) > 0 {
+			_, _ = fmt.Fprintf(buf, "        %s) return 0 ;;\n", strings.Join(cases, "|"))
+		}
+	}
+	_, _ = fmt.Fprintf(buf, "    esac\n    return 1\n}\n\n")
+
+	_, _ = fmt.Fprintf(buf, "%s_dynamic_value()\n{\n    case \"$1|$2\" in\n", fn)
+	for _, node := range nodes {
+		if cases := dynamicValueCases(node, shQuote); len(cases) > 0 {
+			_, _ = fmt.Fprintf(buf, "        %s) return 0 ;;\n", strings.Join(cases, "|"))
+		}
+	}
+	_, _ = fmt.Fprintf(buf, "    esac\n    return 1\n}\n\n")
+
+	_, _ = fmt.Fprintf(buf, "%s_dynamic_args()\n{\n    case \"$1\" in\n", fn)
+	for _, node := range nodes {
+		if node.dynamicArgs {
+			_, _ = fmt.Fprintf(buf, "        %s) return 0 ;;\n", shQuote(node.path))
+		}
+	}
+	_, _ = fmt.Fprintf(buf, "    esac\n    return 1\n}\n\n")
+}
+
+// takesValueCases returns the quoted "path|flag" patterns
+// for all flags of node which require a value.
+func takesValueCases(node *completionNode, quote func(string) string) []string {
+	var cases []string
+	for _, f := range node.flags {
+		if len(f.NoOptDefVal) > 0 {
+			continue
+		}
+		for _, name := range flagNames(f) {
+			cases = append(cases, quote(node.path+"|"+name))
+		}
+	}
+	return cases
+}
+
+// dynamicValueCases returns the quoted "path|flag" patterns for all
+// flags of node whose values are completed by a CompletionFunc.
+func dynamicValueCases(node *completionNode, quote func(string) string) []string {
+	var cases []string
+	for _, f := range node.dynamicFlags {
+		for _, name := range flagNames(f) {
+			cases = append(cases, quote(node.path+"|"+name))
+		}
+	}
+	return cases
+}
+
+// flagNames returns the command line names of f, e.g. "--version" and "-v".
+func flagNames(f *flag.Flag) []string {
+	names := []string{"--" + f.Name}
+	if len(f.Shorthand) > 0 && len(f.ShorthandDeprecated) == 0 {
+		names = append(names, "-"+f.Shorthand)
+	}
+	return names
+}
+
+// completionFunctionName returns the prefix for shell function names used by prog.
+func completionFunctionName(prog string) string {
+	return "__" + invalidFunctionChars.ReplaceAllString(prog, "_")
+}
+
+// zshDescribe returns a "name:description" entry for _describe.
+func zshDescribe(name, description string) string {
+	return strings.ReplaceAll(name, ":", `\:`) + ":" + strings.ReplaceAll(description, "\n", " ")
+}
+
+// shQuote quotes s for use in bash and zsh scripts.
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s for use in fish scripts.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// psQuote quotes s for use in PowerShell scripts.
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,242 @@
+package cflag
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletion(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Hide the 'world' command and define an alias for 'foo'.
+	ctx.cmdWorld.MarkHidden()
+	a.NoError(ctx.cmdFoo.SetAliases("f"))
+	_, err := AddCompletionCommand()
+	a.NoError(err)
+	a.Nil(Parse(ctx.arguments, ctx.flags))
+
+	generators := map[string]func(w *bytes.Buffer) error{
+		"bash":       func(w *bytes.Buffer) error { return GenBashCompletion(w) },
+		"zsh":        func(w *bytes.Buffer) error { return GenZshCompletion(w) },
+		"fish":       func(w *bytes.Buffer) error { return GenFishCompletion(w) },
+		"powershell": func(w *bytes.Buffer) error { return GenPowerShellCompletion(w) },
+	}
+
+	for shell, gen := range generators {
+		buf := new(bytes.Buffer)
+		a.NoError(gen(buf), shell)
+		script := buf.String()
+
+		// Check visible commands and flags.
+		prog := ctx.cmdFoo.GetParent().GetCommandPath()
+		a.Contains(script, prog+" foo", shell)
+		a.Contains(script, prog+" foo bar", shell)
+		a.Contains(script, prog+" types", shell)
+		a.Contains(script, "test2", shell)
+
+		// Check hidden commands.
+		a.NotContains(script, prog+" world", shell)
+		a.NotContains(script, "test3", shell)
+		a.NotContains(script, prog+" "+completionCommandName, shell)
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Check for bash.
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+
+	// Write completion script.
+	ctx.cmdWorld.MarkDeprecated()
+	a.NoError(ctx.cmdFoo.SetAliases("f"))
+	a.Nil(Parse(ctx.arguments, ctx.flags))
+	script := filepath.Join(t.TempDir(), "completion.bash")
+	f, err := os.Create(script)
+	a.NoError(err)
+	a.NoError(GenBashCompletion(f))
+	a.NoError(f.Close())
+	fn := completionFunctionName(ctx.cmdFoo.GetParent().GetCommandPath())
+
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{""}, "foo f types"},
+		{[]string{"f", ""}, "bar"},
+		{[]string{"foo", "-"}, "--test1 --help -h"},
+		{[]string{"--test0", "foo", ""}, "foo f types"},
+		{[]string{"--test0", ""}, ""},
+		{[]string{"--", ""}, ""},
+		{[]string{"foo", "bar", "--t"}, "--test2"},
+	}
+
+	for _, test := range tests {
+		// Run the completion function for the test words.
+		words := append([]string{"prog"}, test.words...)
+		cmd := exec.Command(bash, "-c", `source "$1"; shift; COMP_WORDS=("$@"); COMP_CWORD=$(( $# - 1 )); `+fn+`; echo "${COMPREPLY[*]}"`, "bash", script)
+		cmd.Args = append(cmd.Args, words...)
+		output, err := cmd.Output()
+		a.NoError(err, test.words)
+		t.Logf("%v: %s", test.words, output)
+
+		// Check completions.
+		a.Equal(test.want, strings.TrimSpace(string(output)), test.words)
+	}
+}
+
+func TestCompletionCommand(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Add built-in completion command.
+	cmdCompletion, err := AddCompletionCommand()
+	a.NoError(err)
+	a.True(cmdCompletion.IsHidden())
+
+	// Capture the script written to stdout.
+	output, err := captureOutput(true, false, func() error {
+		return Parse(append(ctx.arguments, "completion", "fish"), ctx.flags)
+	})
+	a.NoError(err)
+	a.Contains(output, "# fish completion for")
+
+	// Check unsupported shell.
+	a.Error(Parse(append(ctx.arguments, "completion", "tcsh"), ctx.flags))
+}
+
+// registerTestCompletions defines completion functions
+// for a flag of 'types' and the arguments of 'foo/bar'.
+func registerTestCompletions(a *assert.Assertions, ctx *testContext) {
+	clusters := func(command *Command, args []string, toComplete string) ([]Completion, CompletionDirective) {
+		return []Completion{{Value: "alpha", Description: "Alpha cluster."}, {Value: "beta"}}, CompletionNoFileComp
+	}
+	files := func(command *Command, args []string, toComplete string) ([]Completion, CompletionDirective) {
+		// Previous arguments and flags are parsed.
+		a.Equal([]string{"alpha"}, args)
+		a.Equal(5, *ctx.paramTest1)
+		return []Completion{{Value: "json"}}, CompletionFilterFileExt
+	}
+
+	a.NoError(ctx.cmdTypes.RegisterFlagCompletionFunc("str", clusters))
+	a.Error(ctx.cmdTypes.RegisterFlagCompletionFunc("other", clusters))
+	a.NoError(ctx.cmdFooBar.AddArg("cluster", "Cluster name."))
+	a.NoError(ctx.cmdFooBar.AddOptionalArg("file", "Config file."))
+	a.NoError(ctx.cmdFooBar.RegisterArgCompletionFunc("cluster", clusters))
+	a.NoError(ctx.cmdFooBar.RegisterArgCompletionFunc("file", files))
+	a.Error(ctx.cmdFooBar.RegisterArgCompletionFunc("other", clusters))
+}
+
+func TestDynamicCompletion(t *testing.T) {
+	a := assert.New(t)
+
+	tests := []struct {
+		args          []string
+		wantValues    []string
+		wantDirective CompletionDirective
+	}{
+		{[]string{"types", "--str", ""}, []string{"alpha", "beta"}, CompletionNoFileComp},
+		{[]string{"types", "-bs", ""}, []string{"alpha", "beta"}, CompletionNoFileComp},
+		{[]string{"types", "--int", ""}, nil, CompletionDefault},
+		{[]string{"types", "--s"}, []string{"--str"}, CompletionNoFileComp},
+		{[]string{"fo"}, []string{"foo"}, CompletionDefault},
+		{[]string{"foo", "bar", ""}, []string{"alpha", "beta"}, CompletionNoFileComp},
+		{[]string{"foo", "--test1", "5", "bar", "alpha", ""}, []string{"json"}, CompletionFilterFileExt},
+	}
+
+	for _, test := range tests {
+		ctx := buildTestContext()
+		registerTestCompletions(a, ctx)
+
+		// Resolve completions for the test arguments.
+		completions, directive := ctx.cmdFoo.GetParent().Complete(test.args)
+		t.Logf("%v: %v %d\n", test.args, completions, directive)
+
+		// Check candidates and directive.
+		var values []string
+		for _, completion := range completions {
+			values = append(values, completion.Value)
+		}
+		a.Equal(test.wantValues, values, test.args)
+		a.Equal(test.wantDirective, directive, test.args)
+
+		// Check that the state of the command tree is reset.
+		a.False(ctx.cmdFoo.IsActive(), test.args)
+		a.False(ctx.flagsFoo.Changed("test1"), test.args)
+		a.Equal(1, *ctx.paramTest1, test.args)
+	}
+}
+
+func TestCompleteRequest(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	registerTestCompletions(a, ctx)
+	SetNoExit()
+
+	// Capture the completions written to stdout.
+	output, err := captureOutput(true, false, func() error {
+		return Parse([]string{ctx.arguments[0], completeCommandName, "types", "--str", ""}, ctx.flags)
+	})
+	a.ErrorIs(err, ErrCompletionRequested)
+	a.Equal("alpha\tAlpha cluster.\nbeta\n:4\n", output)
+}
+
+func TestBashDynamicCompletion(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Check for bash.
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+
+	// Write completion script.
+	registerTestCompletions(a, ctx)
+	a.Nil(Parse(ctx.arguments, ctx.flags))
+	dir := t.TempDir()
+	script := filepath.Join(dir, "completion.bash")
+	f, err := os.Create(script)
+	a.NoError(err)
+	a.NoError(GenBashCompletion(f))
+	a.NoError(f.Close())
+	fn := completionFunctionName(ctx.cmdFoo.GetParent().GetCommandPath())
+
+	// Create a stand-in application answering completion requests.
+	prog := filepath.Join(dir, "prog")
+	a.NoError(os.WriteFile(prog, []byte("#!/bin/sh\nprintf 'alpha\\tAlpha cluster.\\nbeta\\n:4\\n'\n"), 0o755))
+
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"types", "--str", ""}, "alpha beta"},
+		{[]string{"types", "--str", "a"}, "alpha"},
+		{[]string{"types", "--int", ""}, ""},
+		{[]string{"foo", "bar", "b"}, "beta"},
+	}
+
+	for _, test := range tests {
+		// Run the completion function for the test words.
+		words := append([]string{prog}, test.words...)
+		cmd := exec.Command(bash, "-c", `source "$1"; shift; COMP_WORDS=("$@"); COMP_CWORD=$(( $# - 1 )); `+fn+`; echo "${COMPREPLY[*]}"`, "bash", script)
+		cmd.Args = append(cmd.Args, words...)
+		output, err := cmd.Output()
+		a.NoError(err, test.words)
+		t.Logf("%v: %s", test.words, output)
+
+		// Check completions.
+		a.Equal(test.want, strings.TrimSpace(string(output)), test.words)
+	}
+}
@@ -0,0 +1,360 @@
+package cflag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	flag "github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// A FlagSource describes the layer the value of a flag was taken from.
+// Layers with a higher value take precedence.
+type FlagSource int
+
+const (
+	// SourceDefault means the flag holds its default value.
+	SourceDefault FlagSource = iota
+	// SourceConfig means the value was read from the configuration file.
+	SourceConfig
+	// SourceEnv means the value was read from an environment variable.
+	SourceEnv
+	// SourceCommandLine means the value was supplied on the command line.
+	SourceCommandLine
+)
+
+// The name of the built-in flag selecting the configuration file.
+const configFlagName = "config"
+
+// The extensions of the supported configuration file formats in search order.
+var configExtensions = []string{"json", "toml", "yaml", "yml", "ini"}
+
+func (s FlagSource) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceConfig:
+		return "config"
+	case SourceEnv:
+		return "env"
+	case SourceCommandLine:
+		return "command line"
+	default:
+		return "unknown"
+	}
+}
+
+// SetConfigFile enables loading flag values for the command and all its
+// subcommands from a JSON, TOML, YAML or INI configuration file, which is
+// selected by the file extension. The file is supplied using the built-in
+// persistent flag --config or searched in the paths returned by ConfigPaths.
+//
+// Flag values are nested by the names of the subcommands below the command,
+// e.g. the TOML file
+//
+//	test0 = 10
+//	[foo.bar]
+//	test2 = 12
+//
+// sets the flag "test0" of the command and "test2" of the subcommand "foo bar".
+// Only flags which are neither supplied on the command line nor set from the
+// environment are set, i.e. the precedence is defaults < configuration file <
+// environment < command line. See Command.Source.
+func (c *Command) SetConfigFile(name string) *Command {
+	c.configName = name
+
+	// Add the built-in config flag.
+	if c.configFlag == nil {
+		flags := NewFlagSet("", flag.ContinueOnError)
+		flags.String(configFlagName, "", "Configuration file.")
+		c.configFlag = flags.Lookup(configFlagName)
+	}
+	if c.persistent == nil {
+		c.persistent = NewFlagSet("", flag.ContinueOnError)
+	}
+	mergeFlag(c.persistent, c.configFlag)
+
+	return c
+}
+
+// GetConfigFile returns the path of the configuration file loaded
+// by Parse, or an empty string if no file was loaded.
+func (c *Command) GetConfigFile() string {
+	return c.configFile
+}
+
+// ConfigPaths returns the paths searched for the configuration file when
+// --config is not supplied, i.e. "<dir>/<name>/config.<ext>" for each
+// directory in $XDG_CONFIG_HOME (default "~/.config") and $XDG_CONFIG_DIRS
+// (default "/etc/xdg") and each supported extension. See Command.SetConfigFile.
+func (c *Command) ConfigPaths() []string {
+	if len(c.configName) == 0 {
+		return nil
+	}
+
+	// Collect XDG base directories.
+	var dirs []string
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); len(configHome) > 0 {
+		dirs = append(dirs, configHome)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config"))
+	}
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if len(configDirs) == 0 {
+		configDirs = "/etc/xdg"
+	}
+	dirs = append(dirs, filepath.SplitList(configDirs)...)
+
+	var paths []string
+	for _, dir := range dirs {
+		for _, ext := range configExtensions {
+			paths = append(paths, filepath.Join(dir, c.configName, "config."+ext))
+		}
+	}
+	return paths
+}
+
+// Source reports the layer the value of the flag name was taken from.
+// The flag may be inherited from a parent command.
+func (c *Command) Source(name string) FlagSource {
+	f := c.lookupFlag(name)
+	if f == nil {
+		return SourceDefault
+	}
+	if f.Changed {
+		return SourceCommandLine
+	}
+	if owner := c.flagOwner(f); owner != nil {
+		if source, ok := owner.sources[name]; ok {
+			return source
+		}
+	}
+	return SourceDefault
+}
+
+// SetConfigFile enables loading flag values for the application
+// from a configuration file. See Command.SetConfigFile.
+func SetConfigFile(name string) *Command {
+	command.SetConfigFile(name)
+	return &command
+}
+
+// GetConfigFile returns the path of the configuration file loaded by Parse,
+// or an empty string if no file was loaded.
+func GetConfigFile() string {
+	return command.GetConfigFile()
+}
+
+// Source reports the layer the value of the top-level flag name
+// was taken from. See Command.Source.
+func Source(name string) FlagSource {
+	return command.Source(name)
+}
+
+// setSource records the layer the value of the flag name was taken from.
+func (c *Command) setSource(name string, source FlagSource) {
+	if c.sources == nil {
+		c.sources = make(map[string]FlagSource)
+	}
+	c.sources[name] = source
+}
+
+// applyConfig loads the configuration file enabled by a command of cmdChain
+// and sets the flags of this command and all following commands which were
+// neither supplied on the command line nor set from the environment.
+func applyConfig(cmdChain []*Command) error {
+	iConfig := slices.IndexFunc(cmdChain, func(cmd *Command) bool {
+		return len(cmd.configName) > 0
+	})
+	if iConfig < 0 {
+		return nil
+	}
+	configCmd := cmdChain[iConfig]
+
+	// Use the supplied file or search for an existing file.
+	path := configCmd.configFlag.Value.String()
+	if len(path) == 0 {
+		iPath := slices.IndexFunc(configCmd.ConfigPaths(), func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		})
+		if iPath < 0 {
+			return nil
+		}
+		path = configCmd.ConfigPaths()[iPath]
+	}
+
+	tree, err := loadConfig(path)
+	if err != nil {
+		return fmt.Errorf("config file %q: %v", path, err)
+	}
+	configCmd.configFile = path
+
+	// Set flags from the sections named after the command path.
+	var keys []string
+	for i, cmd := range cmdChain[iConfig:] {
+		if i > 0 {
+			keys = append(keys, cmd.name)
+		}
+		section := configSection(tree, keys)
+		if section == nil {
+			continue
+		}
+		cmd.LocalFlags().VisitAll(func(f *flag.Flag) {
+			if err != nil || f.Changed || f.Name == "help" || f == configCmd.configFlag || cmd.sources[f.Name] == SourceEnv {
+				return
+			}
+			value, ok := section[f.Name]
+			if !ok {
+				return
+			}
+
+			// Set the value without marking the flag as changed.
+			setErr := clearResetFlag(f)
+			if setErr == nil {
+				setErr = setConfigValue(f.Value, value)
+			}
+			if setErr != nil {
+				err = &FlagParseError{Command: cmd, Err: fmt.Errorf("invalid value for %q flag in config file %q: %v", "--"+f.Name, path, setErr)}
+				return
+			}
+			cmd.setSource(f.Name, SourceConfig)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadConfig reads the configuration file path into a tree of nested maps.
+// The format is selected by the file extension.
+func loadConfig(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make(map[string]any)
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")); ext {
+	case "json":
+		err = json.Unmarshal(data, &tree)
+	case "toml":
+		err = toml.Unmarshal(data, &tree)
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &tree)
+	case "ini":
+		tree, err = parseINI(data)
+	default:
+		err = fmt.Errorf("unsupported format '%s'", ext)
+	}
+	return tree, err
+}
+
+// parseINI parses an INI file into a tree of nested maps. Section names
+// are split at dots, repeated keys are collected in a list and lines
+// starting with ';' or '#' are comments.
+func parseINI(data []byte) (map[string]any, error) {
+	tree := make(map[string]any)
+	section := tree
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case len(line) == 0 || line[0] == ';' || line[0] == '#':
+			continue
+		case line[0] == '[':
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section %q", i+1, line)
+			}
+			section = tree
+			for _, key := range strings.Split(strings.TrimSpace(line[1:len(line)-1]), ".") {
+				subSection, ok := section[key].(map[string]any)
+				if !ok {
+					subSection = make(map[string]any)
+					section[key] = subSection
+				}
+				section = subSection
+			}
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: missing '=' in %q", i+1, line)
+			}
+			key = strings.TrimSpace(key)
+			value = strings.TrimSpace(value)
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+
+			// Collect repeated keys in a list.
+			switch existing := section[key].(type) {
+			case nil:
+				section[key] = value
+			case []any:
+				section[key] = append(existing, value)
+			default:
+				section[key] = []any{existing, value}
+			}
+		}
+	}
+
+	return tree, nil
+}
+
+// configSection returns the nested map of tree found by following keys,
+// or nil if there is none.
+func configSection(tree map[string]any, keys []string) map[string]any {
+	section := tree
+	for _, key := range keys {
+		subSection, ok := section[key].(map[string]any)
+		if !ok {
+			return nil
+		}
+		section = subSection
+	}
+	return section
+}
+
+// setConfigValue sets value to the configuration value. Each element of a list
+// is set separately. A map is set as comma separated "key=value" pairs.
+func setConfigValue(value flag.Value, configValue any) error {
+	switch configValue := configValue.(type) {
+	case []any:
+		for _, element := range configValue {
+			if err := value.Set(configString(element)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		var pairs []string
+		for key, element := range configValue {
+			pairs = append(pairs, key+"="+configString(element))
+		}
+		sort.Strings(pairs)
+		return value.Set(strings.Join(pairs, ","))
+	default:
+		return value.Set(configString(configValue))
+	}
+}
+
+// configString formats a scalar configuration value for flag.Value.Set.
+func configString(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
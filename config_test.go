@@ -0,0 +1,102 @@
+package cflag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	a := assert.New(t)
+
+	files := map[string]string{
+		"config.json": `{"test0": 10, "foo": {"test1": 11, "bar": {"test2": 12}}, "names": ["a", "b"]}`,
+		"config.toml": "test0 = 10\nnames = [\"a\", \"b\"]\n[foo]\ntest1 = 11\n[foo.bar]\ntest2 = 12\n",
+		"config.yaml": "test0: 10\nnames: [a, b]\nfoo:\n  test1: 11\n  bar:\n    test2: 12\n",
+		"config.ini":  "; comment\ntest0 = 10\nnames = a\nnames = \"b\"\n[foo]\ntest1 = 11\n[foo.bar]\ntest2 = 12\n",
+	}
+
+	for name, content := range files {
+		ctx := buildTestContext()
+
+		// Write configuration file.
+		path := filepath.Join(t.TempDir(), name)
+		a.NoError(os.WriteFile(path, []byte(content), 0o644))
+
+		// Enable configuration file and define a persistent slice flag.
+		flagsGlobal := NewFlagSet("", flag.ContinueOnError)
+		paramNames := flagsGlobal.StringSlice("names", nil, "Names.")
+		SetConfigFile("cflag-test").SetPersistentFlags(flagsGlobal)
+
+		// Run cflag parser. The command line takes precedence.
+		a.Nil(Parse(append(ctx.arguments, "--config", path, "foo", "--test1", "21", "bar"), ctx.flags), name)
+
+		// Check flag values and sources.
+		a.Equal(path, GetConfigFile(), name)
+		a.Equal(10, *ctx.paramTest0, name)
+		a.Equal(21, *ctx.paramTest1, name)
+		a.Equal(12, *ctx.paramTest2, name)
+		a.Equal([]string{"a", "b"}, *paramNames, name)
+		a.False(ctx.flags.Changed("test0"), name)
+		a.Equal(SourceConfig, Source("test0"), name)
+		a.Equal(SourceCommandLine, ctx.cmdFoo.Source("test1"), name)
+		a.Equal(SourceConfig, ctx.cmdFooBar.Source("names"), name)
+		a.Equal(SourceDefault, Source("version"), name)
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Write configuration file to the XDG search path.
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	a.NoError(os.MkdirAll(filepath.Join(dir, "cflag-test"), 0o755))
+	a.NoError(os.WriteFile(filepath.Join(dir, "cflag-test", "config.toml"), []byte("test0 = 10\n[world]\ntest3 = 13\n"), 0o644))
+
+	// Environment variables take precedence over the configuration file.
+	SetConfigFile("cflag-test").BindEnv("APP")
+	t.Setenv("APP_WORLD_TEST3", "23")
+
+	// Run cflag parser.
+	a.Nil(Parse(append(ctx.arguments, "world"), ctx.flags))
+
+	// Check flag values and sources.
+	a.Equal(filepath.Join(dir, "cflag-test", "config.toml"), GetConfigFile())
+	a.Equal(10, *ctx.paramTest0)
+	a.Equal(23, *ctx.paramTest3)
+	a.Equal(SourceConfig, Source("test0"))
+	a.Equal(SourceEnv, ctx.cmdWorld.Source("test3"))
+	a.Equal("env", ctx.cmdWorld.Source("test3").String())
+
+	// Check help output.
+	a.Contains(FlagUsages(), "--config")
+	a.Contains(ctx.cmdWorld.InheritedFlagUsages(), "--config")
+}
+
+func TestConfigInvalid(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	SetConfigFile("cflag-test")
+
+	// Write configuration files.
+	dir := t.TempDir()
+	invalidValue := filepath.Join(dir, "value.json")
+	a.NoError(os.WriteFile(invalidValue, []byte(`{"test0": "abc"}`), 0o644))
+	invalidFormat := filepath.Join(dir, "config.xml")
+	a.NoError(os.WriteFile(invalidFormat, []byte(`<test0>10</test0>`), 0o644))
+
+	// Check errors.
+	err := Parse(append(ctx.arguments, "--config", invalidValue), ctx.flags)
+	t.Log(err)
+	var flagErr *FlagParseError
+	a.ErrorAs(err, &flagErr)
+	err = Parse(append(ctx.arguments, "--config", invalidFormat), ctx.flags)
+	t.Log(err)
+	a.ErrorContains(err, "unsupported format")
+	a.Error(Parse(append(ctx.arguments, "--config", filepath.Join(dir, "missing.json")), ctx.flags))
+}
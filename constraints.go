@@ -0,0 +1,194 @@
+package cflag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// flagGroup holds a constraint for a group of flags.
+type flagGroup struct {
+	kind  flagGroupKind
+	names []string
+}
+
+// flagGroupKind describes the constraint of a flag group.
+type flagGroupKind int
+
+const (
+	// At most one flag of the group may be set.
+	groupMutuallyExclusive flagGroupKind = iota
+	// Either all or no flags of the group must be set.
+	groupRequiredTogether
+	// At least one flag of the group must be set.
+	groupOneRequired
+)
+
+// The flag annotation marking required flags. See Command.MarkFlagRequired.
+const requiredAnnotation = "cflag_required"
+
+// MarkFlagRequired marks the flag name as required. When the command is active
+// and the flag is neither supplied on the command line nor set from the
+// environment or a configuration file, Parse returns a *FlagConstraintError.
+// The flag must be defined in the flag set or the persistent flag set of the command.
+func (c *Command) MarkFlagRequired(name string) error {
+	for _, flags := range []*flag.FlagSet{c.flags, c.persistent} {
+		if flags != nil && flags.Lookup(name) != nil {
+			return flags.SetAnnotation(name, requiredAnnotation, []string{"true"})
+		}
+	}
+	return fmt.Errorf("flag '%s' does not exist", name)
+}
+
+// MutuallyExclusive defines that at most one of the flags names may be set
+// when the command is active. The flags must be defined for the command
+// or inherited from a parent command. See Command.MarkFlagRequired.
+func (c *Command) MutuallyExclusive(names ...string) error {
+	return c.addFlagGroup(groupMutuallyExclusive, names)
+}
+
+// RequiredTogether defines that either all or none of the flags names must be
+// set when the command is active. See Command.MutuallyExclusive.
+func (c *Command) RequiredTogether(names ...string) error {
+	return c.addFlagGroup(groupRequiredTogether, names)
+}
+
+// OneRequired defines that at least one of the flags names must be set
+// when the command is active. See Command.MutuallyExclusive.
+func (c *Command) OneRequired(names ...string) error {
+	return c.addFlagGroup(groupOneRequired, names)
+}
+
+// MarkFlagRequired marks the top-level flag name as required.
+// See Command.MarkFlagRequired.
+func MarkFlagRequired(name string) error {
+	return command.MarkFlagRequired(name)
+}
+
+// MutuallyExclusive defines that at most one of the top-level flags names
+// may be set. See Command.MutuallyExclusive.
+func MutuallyExclusive(names ...string) error {
+	return command.MutuallyExclusive(names...)
+}
+
+// RequiredTogether defines that either all or none of the top-level flags
+// names must be set. See Command.RequiredTogether.
+func RequiredTogether(names ...string) error {
+	return command.RequiredTogether(names...)
+}
+
+// OneRequired defines that at least one of the top-level flags names
+// must be set. See Command.OneRequired.
+func OneRequired(names ...string) error {
+	return command.OneRequired(names...)
+}
+
+// addFlagGroup adds a constraint of kind for the flags names
+// after checking that the flags exist.
+func (c *Command) addFlagGroup(kind flagGroupKind, names []string) error {
+	if len(names) < 2 {
+		return fmt.Errorf("invalid parameters")
+	}
+	for _, name := range names {
+		if c.lookupFlag(name) == nil {
+			return fmt.Errorf("flag '%s' does not exist", name)
+		}
+	}
+
+	c.flagGroups = append(c.flagGroups, &flagGroup{kind: kind, names: names})
+	return nil
+}
+
+// checkFlagConstraints checks the required flags and flag groups of all
+// commands in cmdChain and reports all violations in a single error.
+func checkFlagConstraints(cmdChain []*Command) error {
+	var violations []string
+
+	for _, cmd := range cmdChain {
+		// Check required flags.
+		var missing []string
+		cmd.LocalFlags().VisitAll(func(f *flag.Flag) {
+			if isFlagRequired(f) && cmd.Source(f.Name) == SourceDefault {
+				missing = append(missing, f.Name)
+			}
+		})
+		switch len(missing) {
+		case 0:
+		case 1:
+			violations = append(violations, fmt.Sprintf("required flag %s is not set", quoteFlags(missing)))
+		default:
+			violations = append(violations, fmt.Sprintf("required flags %s are not set", quoteFlags(missing)))
+		}
+
+		// Check flag groups.
+		for _, group := range cmd.flagGroups {
+			var set, unset []string
+			for _, name := range group.names {
+				if cmd.Source(name) == SourceDefault {
+					unset = append(unset, name)
+				} else {
+					set = append(set, name)
+				}
+			}
+
+			switch {
+			case group.kind == groupMutuallyExclusive && len(set) > 1:
+				violations = append(violations, fmt.Sprintf("flags %s are mutually exclusive, but %s were set", quoteFlags(group.names), quoteFlags(set)))
+			case group.kind == groupRequiredTogether && len(set) > 0 && len(unset) > 0:
+				violations = append(violations, fmt.Sprintf("flags %s must be set together, but %s %s not set", quoteFlags(group.names), quoteFlags(unset), pluralVerb(len(unset))))
+			case group.kind == groupOneRequired && len(set) == 0:
+				violations = append(violations, fmt.Sprintf("one of the flags %s must be set", quoteFlags(group.names)))
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &FlagConstraintError{Command: cmdChain[len(cmdChain)-1], Violations: violations}
+	}
+	return nil
+}
+
+// flagGroupUsages returns a string containing a note
+// for each flag group defined for this command.
+func (c *Command) flagGroupUsages() string {
+	buf := new(strings.Builder)
+	for _, group := range c.flagGroups {
+		flags := quoteFlags(group.names)
+		gap := strings.Repeat(" ", commandGapLen)
+		switch group.kind {
+		case groupMutuallyExclusive:
+			_, _ = fmt.Fprintf(buf, "%sFlags %s are mutually exclusive.\n", gap, flags)
+		case groupRequiredTogether:
+			_, _ = fmt.Fprintf(buf, "%sFlags %s must be set together.\n", gap, flags)
+		case groupOneRequired:
+			_, _ = fmt.Fprintf(buf, "%sOne of the flags %s is required.\n", gap, flags)
+		}
+	}
+	return buf.String()
+}
+
+// isFlagRequired reports whether f is marked as required.
+func isFlagRequired(f *flag.Flag) bool {
+	values, ok := f.Annotations[requiredAnnotation]
+	return ok && len(values) > 0 && values[0] == "true"
+}
+
+// quoteFlags returns the quoted command line names of the flags names
+// separated by commas, e.g. `"--a", "--b"`.
+func quoteFlags(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote("--" + name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// pluralVerb returns "was" for a count of 1 and "were" otherwise.
+func pluralVerb(count int) string {
+	if count == 1 {
+		return "was"
+	}
+	return "were"
+}
@@ -0,0 +1,73 @@
+package cflag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildConstraintsTestContext returns a test context
+// with required flags and flag groups.
+func buildConstraintsTestContext(a *assert.Assertions) *testContext {
+	ctx := buildTestContext()
+	a.NoError(ctx.cmdFoo.MarkFlagRequired("test1"))
+	a.Error(ctx.cmdFoo.MarkFlagRequired("test0"))
+	a.NoError(ctx.cmdTypes.MutuallyExclusive("bool", "int"))
+	a.NoError(ctx.cmdTypes.RequiredTogether("int", "str"))
+	a.NoError(ctx.cmdTypes.OneRequired("bool", "int", "str"))
+	a.Error(ctx.cmdTypes.OneRequired("bool"))
+	a.Error(ctx.cmdTypes.MutuallyExclusive("bool", "other"))
+	return ctx
+}
+
+func TestFlagConstraints(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildConstraintsTestContext(a)
+
+	// Check help output.
+	output := ctx.cmdTypes.CommandUsage()
+	t.Log(output)
+	a.Contains(output, `Flags "--bool", "--int" are mutually exclusive.`)
+	a.Contains(output, `Flags "--int", "--str" must be set together.`)
+	a.Contains(output, `One of the flags "--bool", "--int", "--str" is required.`)
+	a.Contains(ctx.cmdFoo.FlagUsages(), "Test 1. (required)")
+
+	// Satisfy all constraints.
+	a.Nil(Parse(append(ctx.arguments, "foo", "--test1", "11"), ctx.flags))
+	a.NoError(ResetState())
+	a.Nil(Parse(append(ctx.arguments, "types", "-i", "1", "-s", "a"), ctx.flags))
+
+	// Violate constraints.
+	tests := []struct {
+		args       []string
+		violations []string
+	}{
+		{[]string{"foo", "bar"}, []string{`required flag "--test1" is not set`}},
+		{[]string{"types"}, []string{`one of the flags "--bool", "--int", "--str" must be set`}},
+		{[]string{"types", "-b", "-i", "1"}, []string{
+			`flags "--bool", "--int" are mutually exclusive, but "--bool", "--int" were set`,
+			`flags "--int", "--str" must be set together, but "--str" was not set`,
+		}},
+	}
+	for _, test := range tests {
+		a.NoError(ResetState())
+		err := Parse(append(ctx.arguments, test.args...), ctx.flags)
+		t.Log(err)
+		var constraintErr *FlagConstraintError
+		if a.ErrorAs(err, &constraintErr) {
+			a.Equal(test.violations, constraintErr.Violations)
+		}
+	}
+}
+
+func TestFlagConstraintsEnv(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// A required flag set from the environment satisfies the requirement.
+	a.NoError(ctx.cmdWorld.MarkFlagRequired("test3"))
+	ctx.cmdWorld.BindEnv("WORLD")
+	t.Setenv("WORLD_TEST3", "30")
+	a.Nil(Parse(append(ctx.arguments, "world"), ctx.flags))
+	a.Equal(30, *ctx.paramTest3)
+}
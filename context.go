@@ -0,0 +1,141 @@
+package cflag
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	flag "github.com/spf13/pflag"
+)
+
+// ExecuteContext parses the command line arguments like Parse and passes
+// ctx to the executed callback. See Command.SetContextCallback.
+// With SetCancelOnSignal, ctx is cancelled when SIGINT or SIGTERM is received.
+func (c *Command) ExecuteContext(ctx context.Context, arguments []string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// Cancel the context on the first signal.
+	if c.sigCancel || command.sigCancel {
+		var stop func()
+		ctx, stop = withSignalCancel(ctx)
+		defer stop()
+	}
+
+	// Restore the context of an enclosing execution, e.g. of the shell.
+	prevCtx := c.ctx
+	c.ctx = ctx
+	defer func() {
+		c.ctx = prevCtx
+	}()
+
+	return c.Parse(arguments)
+}
+
+// SetCancelOnSignal enables cancelling the context passed to the callback
+// by ExecuteContext when SIGINT or SIGTERM is received, so that long-running
+// callbacks can shut down cleanly. A second signal exits the application
+// immediately with the exit code 128 + signal number. When ExecuteContext
+// is nested, e.g. for the lines of a shell, signals only cancel the context
+// of the innermost execution.
+func (c *Command) SetCancelOnSignal() *Command {
+	c.sigCancel = true
+	return c
+}
+
+// Context returns the context passed to ExecuteContext for the command tree
+// the command belongs to, or context.Background() while not executed via
+// ExecuteContext.
+func (c *Command) Context() context.Context {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if cmd.ctx != nil {
+			return cmd.ctx
+		}
+	}
+	return context.Background()
+}
+
+// ExecuteContext parses the application command line arguments like Parse
+// and passes ctx to the executed callback. See Command.ExecuteContext.
+func ExecuteContext(ctx context.Context, arguments []string, flags *flag.FlagSet) error {
+	command.flags = flags
+	return command.ExecuteContext(ctx, arguments)
+}
+
+// SetCancelOnSignal enables cancelling the context passed to the callback
+// by ExecuteContext on SIGINT or SIGTERM. See Command.SetCancelOnSignal.
+func SetCancelOnSignal() *Command {
+	command.SetCancelOnSignal()
+	return &command
+}
+
+// The number of active signal handlers created by withSignalCancel.
+// Only the innermost handler reacts to signals.
+var (
+	signalMu    sync.Mutex
+	signalDepth int
+)
+
+// withSignalCancel returns a copy of ctx which is cancelled when SIGINT or
+// SIGTERM is received. A second signal exits the application. Signals are
+// ignored while a nested handler is active. Call stop to release the
+// resources and stop listening for signals.
+func withSignalCancel(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	signals := make(chan os.Signal, 2)
+	done := make(chan struct{})
+
+	signalMu.Lock()
+	signalDepth++
+	depth := signalDepth
+	signalMu.Unlock()
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	// receive returns the next signal handled by this handler,
+	// or nil when stop was called.
+	receive := func() os.Signal {
+		for {
+			select {
+			case sig := <-signals:
+				signalMu.Lock()
+				innermost := signalDepth == depth
+				signalMu.Unlock()
+				if innermost {
+					return sig
+				}
+			case <-done:
+				return nil
+			}
+		}
+	}
+
+	go func() {
+		// Cancel the context on the first signal.
+		if receive() == nil {
+			return
+		}
+		cancel()
+
+		// Exit on the second signal.
+		if sig := receive(); sig != nil {
+			if sysSig, ok := sig.(syscall.Signal); ok {
+				os.Exit(128 + int(sysSig))
+			}
+			os.Exit(1)
+		}
+	}()
+
+	stop := func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+
+		signalMu.Lock()
+		signalDepth--
+		signalMu.Unlock()
+	}
+	return ctx, stop
+}
@@ -0,0 +1,69 @@
+package cflag
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+type testContextKey struct{}
+
+func TestExecuteContext(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Check the context passed to context and plain callbacks.
+	called := 0
+	ctx.cmdFoo.SetContextCallback(func(ctx context.Context, command *Command, flags *flag.FlagSet) error {
+		called++
+		a.Equal("value", ctx.Value(testContextKey{}))
+		a.Equal(ctx, command.Context())
+		return nil
+	})
+	ctx.cmdWorld.SetCallback(func(command *Command, flags *flag.FlagSet) error {
+		called++
+		a.Equal("value", command.Context().Value(testContextKey{}))
+		return nil
+	})
+
+	// Run cflag parser.
+	parent := context.WithValue(context.Background(), testContextKey{}, "value")
+	a.Nil(ExecuteContext(parent, append(ctx.arguments, "foo", "bar"), ctx.flags))
+	a.Nil(ExecuteContext(parent, append(ctx.arguments, "world"), ctx.flags))
+	a.Equal(2, called)
+
+	// Parse uses the background context.
+	a.Equal(context.Background(), ctx.cmdFoo.Context())
+}
+
+func TestCancelOnSignal(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not supported")
+	}
+
+	// Wait for the context to be cancelled by an interrupt signal.
+	SetCancelOnSignal()
+	ctx.cmdFoo.SetContextCallback(func(ctx context.Context, command *Command, flags *flag.FlagSet) error {
+		process, err := os.FindProcess(os.Getpid())
+		a.NoError(err)
+		a.NoError(process.Signal(os.Interrupt))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+
+	// Run cflag parser.
+	err := ExecuteContext(context.Background(), append(ctx.arguments, "foo"), ctx.flags)
+	a.ErrorIs(err, context.Canceled)
+}
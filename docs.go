@@ -0,0 +1,302 @@
+package cflag
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// A FileNameHandler returns the name of the documentation file
+// for the command. See GenMarkdownTreeCustom.
+type FileNameHandler func(cmd *Command) string
+
+// A LinkHandler returns the link target referring to the documentation
+// of the command, e.g. a relative file name or a URL. See GenMarkdownTreeCustom.
+type LinkHandler func(cmd *Command) string
+
+// GenMarkdownTree writes a Markdown page for root and each command below root
+// to dir, skipping hidden commands which are not deprecated. The pages are
+// named after the command paths, e.g. "app-foo-bar.md" for the command
+// "app foo bar", and link to each other by their file names.
+func GenMarkdownTree(root *Command, dir string) error {
+	return GenMarkdownTreeCustom(root, dir, nil, nil)
+}
+
+// GenMarkdownTreeCustom writes Markdown pages like GenMarkdownTree, using
+// fileName to name the files and link to create the links between pages.
+// A nil handler selects the default behavior of GenMarkdownTree.
+func GenMarkdownTreeCustom(root *Command, dir string, fileName FileNameHandler, link LinkHandler) error {
+	if fileName == nil {
+		fileName = func(cmd *Command) string {
+			return manPageName(cmd) + ".md"
+		}
+	}
+	if link == nil {
+		link = LinkHandler(fileName)
+	}
+	return genDocTree(root, dir, fileName, func(cmd *Command, w io.Writer) error {
+		return GenMarkdown(cmd, w, link)
+	})
+}
+
+// GenReSTTree writes a reStructuredText page for root and each command below
+// root to dir, skipping hidden commands which are not deprecated. The pages
+// are named after the command paths, e.g. "app-foo-bar.rst" for the command
+// "app foo bar", and link to each other by the HTML file names built from them.
+func GenReSTTree(root *Command, dir string) error {
+	return GenReSTTreeCustom(root, dir, nil, nil)
+}
+
+// GenReSTTreeCustom writes reStructuredText pages like GenReSTTree, using
+// fileName to name the files and link to create the links between pages.
+// A nil handler selects the default behavior of GenReSTTree.
+func GenReSTTreeCustom(root *Command, dir string, fileName FileNameHandler, link LinkHandler) error {
+	if fileName == nil {
+		fileName = func(cmd *Command) string {
+			return manPageName(cmd) + ".rst"
+		}
+	}
+	if link == nil {
+		link = func(cmd *Command) string {
+			return manPageName(cmd) + ".html"
+		}
+	}
+	return genDocTree(root, dir, fileName, func(cmd *Command, w io.Writer) error {
+		return GenReST(cmd, w, link)
+	})
+}
+
+// GenMarkdown writes a Markdown page for the command to w.
+// link may be nil to link to the default file names. See GenMarkdownTree.
+func GenMarkdown(cmd *Command, w io.Writer, link LinkHandler) error {
+	if link == nil {
+		link = func(cmd *Command) string {
+			return manPageName(cmd) + ".md"
+		}
+	}
+	mdLink := func(label string, cmd *Command) string {
+		return "[" + mdEscape(label) + "](" + link(cmd) + ")"
+	}
+	buf := new(bytes.Buffer)
+
+	// Add breadcrumbs and title.
+	if cmd.parent != nil {
+		var crumbs []string
+		for parent := cmd.parent; parent != nil; parent = parent.parent {
+			crumbs = append([]string{mdLink(docName(parent), parent)}, crumbs...)
+		}
+		_, _ = fmt.Fprintf(buf, "%s > %s\n\n", strings.Join(crumbs, " > "), mdEscape(cmd.GetName()))
+	}
+	_, _ = fmt.Fprintf(buf, "# %s\n\n", mdEscape(cmd.GetCommandPath()))
+	if len(cmd.usage) > 0 {
+		_, _ = fmt.Fprintf(buf, "%s\n\n", mdEscape(cmd.usage))
+	}
+	if cmd.deprecated {
+		buf.WriteString("> **Deprecated:** This command is deprecated and may be removed in a future version.\n\n")
+	}
+
+	// Add synopsis and description.
+	_, _ = fmt.Fprintf(buf, "## Synopsis\n\n```\n%s%s\n```\n\n", cmd.GetCommandPath(), synopsisArgs(cmd))
+	if len(cmd.description) > 0 {
+		_, _ = fmt.Fprintf(buf, "%s\n\n", cmd.description)
+	}
+
+	// Add subcommands.
+	if cmds := docCommands(cmd); len(cmds) > 0 {
+		buf.WriteString("## Commands\n\n| Command | Aliases | Description |\n| --- | --- | --- |\n")
+		for _, subCmd := range cmds {
+			_, _ = fmt.Fprintf(buf, "| %s | %s | %s |\n", mdLink(subCmd.GetName(), subCmd),
+				mdCell(strings.Join(subCmd.aliases, ", ")), mdCell(subCmd.usage))
+		}
+		buf.WriteString("\n")
+	}
+
+	// Add flags.
+	writeMarkdownFlags(buf, "Flags", cmd.LocalFlags())
+	writeMarkdownFlags(buf, "Global Flags", cmd.InheritedFlags())
+
+	_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return err
+}
+
+// GenReST writes a reStructuredText page for the command to w.
+// link may be nil to link to the default HTML file names. See GenReSTTree.
+func GenReST(cmd *Command, w io.Writer, link LinkHandler) error {
+	if link == nil {
+		link = func(cmd *Command) string {
+			return manPageName(cmd) + ".html"
+		}
+	}
+	rstLink := func(label string, cmd *Command) string {
+		return "`" + rstEscape(label) + " <" + link(cmd) + ">`__"
+	}
+	buf := new(bytes.Buffer)
+
+	// Add breadcrumbs and title.
+	if cmd.parent != nil {
+		var crumbs []string
+		for parent := cmd.parent; parent != nil; parent = parent.parent {
+			crumbs = append([]string{rstLink(docName(parent), parent)}, crumbs...)
+		}
+		_, _ = fmt.Fprintf(buf, "%s > %s\n\n", strings.Join(crumbs, " > "), rstEscape(cmd.GetName()))
+	}
+	writeReSTTitle(buf, cmd.GetCommandPath(), "=")
+	if len(cmd.usage) > 0 {
+		_, _ = fmt.Fprintf(buf, "%s\n\n", rstEscape(cmd.usage))
+	}
+	if cmd.deprecated {
+		buf.WriteString(".. warning::\n\n   This command is deprecated and may be removed in a future version.\n\n")
+	}
+
+	// Add synopsis and description.
+	writeReSTTitle(buf, "Synopsis", "-")
+	_, _ = fmt.Fprintf(buf, "::\n\n   %s%s\n\n", cmd.GetCommandPath(), synopsisArgs(cmd))
+	if len(cmd.description) > 0 {
+		_, _ = fmt.Fprintf(buf, "%s\n\n", cmd.description)
+	}
+
+	// Add subcommands.
+	if cmds := docCommands(cmd); len(cmds) > 0 {
+		writeReSTTitle(buf, "Commands", "-")
+		var rows [][]string
+		for _, subCmd := range cmds {
+			rows = append(rows, []string{rstLink(subCmd.GetName(), subCmd),
+				rstEscape(strings.Join(subCmd.aliases, ", ")), rstEscape(subCmd.usage)})
+		}
+		writeReSTTable(buf, []string{"Command", "Aliases", "Description"}, rows)
+	}
+
+	// Add flags.
+	writeReSTFlags(buf, "Flags", cmd.LocalFlags())
+	writeReSTFlags(buf, "Global Flags", cmd.InheritedFlags())
+
+	_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return err
+}
+
+// genDocTree writes a documentation page generated by gen for root
+// and each command below root to dir, named by fileName.
+func genDocTree(root *Command, dir string, fileName FileNameHandler, gen func(cmd *Command, w io.Writer) error) error {
+	return walkDoc(root, func(cmd *Command) error {
+		f, err := os.Create(filepath.Join(dir, fileName(cmd)))
+		if err != nil {
+			return err
+		}
+		if err := gen(cmd, f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// docName returns the name of c, or the command path for top-level commands.
+func docName(c *Command) string {
+	if c.parent == nil {
+		return c.GetCommandPath()
+	}
+	return c.name
+}
+
+// docFlagRows returns a row containing the name, shorthand, type, default
+// and usage of each visible flag in flags, formatted by code and escape.
+func docFlagRows(flags *flag.FlagSet, code, escape func(s string) string) [][]string {
+	var rows [][]string
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Hidden || len(f.Deprecated) > 0 {
+			return
+		}
+
+		var shorthand, def string
+		if len(f.Shorthand) > 0 && len(f.ShorthandDeprecated) == 0 {
+			shorthand = code("-" + f.Shorthand)
+		}
+		if !isZeroDefault(f) {
+			def = code(flagDefault(f))
+		}
+		_, usage := flag.UnquoteUsage(f)
+		rows = append(rows, []string{code("--" + f.Name), shorthand, escape(f.Value.Type()), def, escape(usage)})
+	})
+	return rows
+}
+
+// writeMarkdownFlags writes a Markdown section titled title
+// containing a table of the visible flags of flags to buf.
+func writeMarkdownFlags(buf *bytes.Buffer, title string, flags *flag.FlagSet) {
+	rows := docFlagRows(flags, mdCode, mdCell)
+	if len(rows) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(buf, "## %s\n\n| Flag | Shorthand | Type | Default | Description |\n| --- | --- | --- | --- | --- |\n", title)
+	for _, row := range rows {
+		_, _ = fmt.Fprintf(buf, "| %s |\n", strings.Join(row, " | "))
+	}
+	buf.WriteString("\n")
+}
+
+// writeReSTFlags writes a reStructuredText section titled title
+// containing a table of the visible flags of flags to buf.
+func writeReSTFlags(buf *bytes.Buffer, title string, flags *flag.FlagSet) {
+	rows := docFlagRows(flags, rstCode, rstEscape)
+	if len(rows) == 0 {
+		return
+	}
+
+	writeReSTTitle(buf, title, "-")
+	writeReSTTable(buf, []string{"Flag", "Shorthand", "Type", "Default", "Description"}, rows)
+}
+
+// writeReSTTitle writes title underlined with the character underline to buf.
+func writeReSTTitle(buf *bytes.Buffer, title, underline string) {
+	_, _ = fmt.Fprintf(buf, "%s\n%s\n\n", rstEscape(title), strings.Repeat(underline, len(rstEscape(title))))
+}
+
+// writeReSTTable writes a list table with the header and rows to buf.
+func writeReSTTable(buf *bytes.Buffer, header []string, rows [][]string) {
+	buf.WriteString(".. list-table::\n   :header-rows: 1\n\n")
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			prefix := "     -"
+			if i == 0 {
+				prefix = "   * -"
+			}
+			if len(cell) > 0 {
+				prefix += " " + cell
+			}
+			buf.WriteString(prefix + "\n")
+		}
+	}
+	buf.WriteString("\n")
+}
+
+// mdEscape escapes characters with a special meaning in Markdown text.
+func mdEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`).Replace(s)
+}
+
+// mdCell escapes s for use in a Markdown table cell.
+func mdCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(mdEscape(s), "|", `\|`), "\n", " ")
+}
+
+// mdCode returns s formatted as Markdown inline code for use in a table cell.
+func mdCode(s string) string {
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+// rstEscape escapes characters with a special meaning
+// in reStructuredText inline markup.
+func rstEscape(s string) string {
+	return strings.ReplaceAll(strings.NewReplacer(`\`, `\\`, "*", `\*`, "`", "\\`", "|", `\|`, "_", `\_`).Replace(s), "\n", " ")
+}
+
+// rstCode returns s formatted as reStructuredText inline literal.
+func rstCode(s string) string {
+	return "``" + s + "``"
+}
@@ -0,0 +1,95 @@
+package cflag
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildDocsTestContext returns a test context with aliases,
+// shorthands and string defaults for documentation tests.
+func buildDocsTestContext(a *assert.Assertions) *testContext {
+	ctx := buildTestContext()
+	a.NoError(ctx.cmdFooBar.SetAliases("b"))
+	a.NoError(ctx.cmdFooBar.AddArg("file", "Input file."))
+	ctx.flagsFoo.StringP("name", "n", "world", "Name to greet | salute.")
+	ctx.flagsFoo.StringToInt("limit", map[string]int{"d": 4, "b": 2, "a": 1, "c": 3}, "Limits.")
+	command.flags = ctx.flags
+	return ctx
+}
+
+func TestGenMarkdownTree(t *testing.T) {
+	a := assert.New(t)
+	buildDocsTestContext(a)
+	prog := manPageName(&command)
+
+	// Generate documentation twice to check that the output is deterministic.
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	a.NoError(GenMarkdownTree(&command, dir1))
+	a.NoError(GenMarkdownTree(&command, dir2))
+	for _, name := range []string{prog + ".md", prog + "-foo.md", prog + "-foo-bar.md", prog + "-world.md", prog + "-types.md"} {
+		data1, err := os.ReadFile(filepath.Join(dir1, name))
+		a.NoError(err)
+		data2, _ := os.ReadFile(filepath.Join(dir2, name))
+		a.Equal(data1, data2, name)
+	}
+
+	// Check page content.
+	data, _ := os.ReadFile(filepath.Join(dir1, prog+"-foo.md"))
+	page := string(data)
+	t.Log(page)
+	a.Contains(page, "["+mdEscape(prog)+"]("+prog+".md) > foo\n\n# "+mdEscape(prog)+" foo\n\nFoo command.\n")
+	a.Contains(page, "## Synopsis\n\n```\n"+prog+" foo [flags] [command]\n```\n\nFoo command description.\n")
+	a.Contains(page, "| ["+"bar]("+prog+"-foo-bar.md) | b | Bar command. |\n")
+	a.Contains(page, "| `--test1` |  | int | `1` | Test 1. |\n")
+	a.Contains(page, "| `--name` | `-n` | string | `\"world\"` | Name to greet \\| salute. |\n")
+	a.Contains(page, "| `--limit` |  | stringToInt | `[a=1,b=2,c=3,d=4]` | Limits. |\n")
+	a.NotContains(page, "## Global Flags\n")
+
+	data, _ = os.ReadFile(filepath.Join(dir1, prog+"-foo-bar.md"))
+	a.Contains(string(data), prog+" foo bar [flags] <file>\n")
+}
+
+func TestGenMarkdownTreeCustom(t *testing.T) {
+	a := assert.New(t)
+	buildDocsTestContext(a)
+
+	// Use custom file names and absolute links.
+	fileName := func(cmd *Command) string {
+		return strings.ReplaceAll(cmd.GetCommandPath(), " ", "_") + ".markdown"
+	}
+	link := func(cmd *Command) string {
+		return "/docs/" + strings.ReplaceAll(cmd.GetCommandPath(), " ", "/")
+	}
+	dir := t.TempDir()
+	a.NoError(GenMarkdownTreeCustom(&command, dir, fileName, link))
+
+	data, err := os.ReadFile(filepath.Join(dir, manPageName(&command)+"_foo_bar.markdown"))
+	a.NoError(err)
+	t.Log(string(data))
+	a.Contains(string(data), "](/docs/"+command.GetCommandPath()+"/foo) > bar\n")
+}
+
+func TestGenReSTTree(t *testing.T) {
+	a := assert.New(t)
+	buildDocsTestContext(a)
+	prog := manPageName(&command)
+
+	// Generate documentation.
+	dir := t.TempDir()
+	a.NoError(GenReSTTree(&command, dir))
+
+	// Check page content.
+	data, err := os.ReadFile(filepath.Join(dir, prog+"-foo.rst"))
+	a.NoError(err)
+	page := string(data)
+	t.Log(page)
+	a.Contains(page, "`"+rstEscape(prog)+" <"+prog+".html>`__ > foo\n\n")
+	a.Contains(page, "Synopsis\n--------\n\n::\n\n   "+prog+" foo [flags] [command]\n\n")
+	a.Contains(page, "   * - `bar <"+prog+"-foo-bar.html>`__\n     - b\n     - Bar command.\n")
+	a.Contains(page, "   * - ``--name``\n     - ``-n``\n     - string\n     - ``\"world\"``\n     - Name to greet \\| salute.\n")
+	a.Contains(page, "   * - ``--test1``\n     -\n     - int\n")
+}
@@ -0,0 +1,133 @@
+package cflag
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// Matches all characters which are not allowed in environment variable names.
+var invalidEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// BindEnv binds the flags of the command and all its subcommands to
+// environment variables. After parsing, flags which were not supplied
+// on the command line are set from the environment. flag.FlagSet.Changed
+// still reports only flags supplied on the command line.
+// The variable names consist of prefix, the names of the subcommands
+// below this command and the flag name, converted to upper case and
+// separated by underscores, e.g. "APP_FOO_BAR_TEST2" for the flag "test2"
+// of the command "foo bar" with prefix "APP".
+func (c *Command) BindEnv(prefix string) *Command {
+	c.envPrefix = prefix
+	return c
+}
+
+// BindFlagEnv binds the flag name to the environment variable variable,
+// overriding the name derived from BindEnv. The flag must be defined in the
+// flag set or the persistent flag set of the command.
+func (c *Command) BindFlagEnv(name string, variable string) error {
+	if len(name) == 0 || len(variable) == 0 {
+		return fmt.Errorf("invalid parameters")
+	}
+
+	// Check if the flag is defined for this command.
+	if (c.flags == nil || c.flags.Lookup(name) == nil) && (c.persistent == nil || c.persistent.Lookup(name) == nil) {
+		return fmt.Errorf("flag '%s' does not exist", name)
+	}
+
+	if c.envVars == nil {
+		c.envVars = make(map[string]string)
+	}
+	c.envVars[name] = variable
+	return nil
+}
+
+// FlagEnv returns the name of the environment variable bound to the flag
+// name of the command, which may be inherited from a parent command,
+// or an empty string if the flag is not bound. See Command.BindEnv.
+func (c *Command) FlagEnv(name string) string {
+	if f := c.lookupFlag(name); f != nil {
+		return c.flagEnv(f)
+	}
+	return ""
+}
+
+// BindEnv binds the flags of the application and all commands
+// to environment variables. See Command.BindEnv.
+func BindEnv(prefix string) *Command {
+	command.BindEnv(prefix)
+	return &command
+}
+
+// BindFlagEnv binds the top-level flag name to the environment
+// variable variable. See Command.BindFlagEnv.
+func BindFlagEnv(name string, variable string) error {
+	return command.BindFlagEnv(name, variable)
+}
+
+// applyEnv sets all flags defined for the command, excluding inherited flags,
+// which were not supplied on the command line from the bound environment variables.
+func (c *Command) applyEnv() error {
+	var err error
+	c.LocalFlags().VisitAll(func(f *flag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" {
+			return
+		}
+		env := c.flagEnv(f)
+		if len(env) == 0 {
+			return
+		}
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			return
+		}
+
+		// Set the value without marking the flag as changed.
+		setErr := clearResetFlag(f)
+		if setErr == nil {
+			setErr = f.Value.Set(value)
+		}
+		if setErr != nil {
+			err = &FlagParseError{Command: c, Err: fmt.Errorf("invalid argument %q for %q flag from environment variable %s: %v", value, "--"+f.Name, env, setErr)}
+			return
+		}
+		c.setSource(f.Name, SourceEnv)
+	})
+	return err
+}
+
+// flagEnv returns the name of the environment variable bound to f,
+// or an empty string if f is not bound. The name is derived from the
+// command defining f, which may be a parent command of c.
+func (c *Command) flagEnv(f *flag.Flag) string {
+	owner := c.flagOwner(f)
+	if owner == nil {
+		return ""
+	}
+
+	// Use the variable bound to the flag.
+	if env, ok := owner.envVars[f.Name]; ok {
+		return env
+	}
+
+	// Derive the variable from the nearest prefix and the command path.
+	parts := []string{f.Name}
+	for cmd := owner; cmd != nil; cmd = cmd.parent {
+		if len(cmd.envPrefix) > 0 {
+			parts = append(parts, cmd.envPrefix)
+			break
+		}
+		if cmd.parent == nil {
+			return ""
+		}
+		parts = append(parts, cmd.name)
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+
+	return invalidEnvChars.ReplaceAllString(strings.ToUpper(strings.Join(parts, "_")), "_")
+}
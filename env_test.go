@@ -0,0 +1,74 @@
+package cflag
+
+import (
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnv(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Bind flags to environment variables.
+	flagsGlobal := NewFlagSet("", flag.ContinueOnError)
+	paramVerbose := flagsGlobal.BoolP("verbose", "V", false, "Verbose output.")
+	SetPersistentFlags(flagsGlobal)
+	BindEnv("APP")
+	a.NoError(ctx.cmdFoo.BindFlagEnv("test1", "TEST_ONE"))
+	a.Error(ctx.cmdFoo.BindFlagEnv("test2", "TEST_TWO"))
+	t.Setenv("APP_TEST0", "10")
+	t.Setenv("TEST_ONE", "11")
+	t.Setenv("APP_FOO_BAR_TEST2", "12")
+	t.Setenv("APP_VERBOSE", "true")
+
+	// Setup test arguments. The command line takes precedence.
+	ctx.arguments = append(ctx.arguments,
+		[]string{"--test0", "20", "foo", "bar"}...,
+	)
+
+	// Run cflag parser.
+	a.Nil(Parse(ctx.arguments, ctx.flags))
+
+	// Check flag values and change state.
+	a.Equal(20, *ctx.paramTest0)
+	a.True(ctx.flags.Changed("test0"))
+	a.Equal(11, *ctx.paramTest1)
+	a.False(ctx.flagsFoo.Changed("test1"))
+	a.Equal(12, *ctx.paramTest2)
+	a.False(ctx.flagsFooBar.Changed("test2"))
+	a.True(*paramVerbose)
+	a.False(flagsGlobal.Changed("verbose"))
+
+	// Check variable names in help output.
+	a.Equal("APP_FOO_BAR_TEST2", ctx.cmdFooBar.FlagEnv("test2"))
+	a.Equal("APP_VERBOSE", ctx.cmdFooBar.FlagEnv("verbose"))
+	a.Equal("", ctx.cmdFooBar.FlagEnv("other"))
+	output := ctx.cmdFooBar.CommandUsage()
+	t.Log(output)
+	a.Contains(output, "Test 2. [$APP_FOO_BAR_TEST2]")
+	a.Contains(output, "Verbose output. [$APP_VERBOSE]")
+	a.Contains(ctx.cmdFoo.FlagUsages(), "[$TEST_ONE]")
+}
+
+func TestEnvInvalid(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Bind the 'world' command flags only.
+	ctx.cmdWorld.BindEnv("WORLD")
+	t.Setenv("WORLD_TEST3", "abc")
+	t.Setenv("APP_TEST0", "10")
+
+	// Run cflag parser.
+	err := Parse(append(ctx.arguments, "world"), ctx.flags)
+	t.Log(err)
+
+	// Check error and unbound flags.
+	var flagErr *FlagParseError
+	a.ErrorAs(err, &flagErr)
+	a.Equal(ctx.cmdWorld, flagErr.Command)
+	a.Contains(err.Error(), "WORLD_TEST3")
+	a.Equal(0, *ctx.paramTest0)
+}
@@ -0,0 +1,136 @@
+package cflag
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrHelpRequested is returned by Parse in no-exit mode
+// when -h, --help is supplied to a command. See SetNoExit.
+var ErrHelpRequested = errors.New("help requested")
+
+// ErrCompletionRequested is returned by Parse in no-exit mode after the
+// completions for a "__complete" request were written. See SetNoExit.
+var ErrCompletionRequested = errors.New("completion requested")
+
+// ErrPluginExecuted is returned by Parse in no-exit mode after
+// a plugin exited successfully. See Command.EnablePlugins.
+var ErrPluginExecuted = errors.New("plugin executed")
+
+// An UnknownCommandError is returned by Parse when the arguments
+// do not start with the name of the command being parsed, or when
+// a command with subcommands but without declared positional arguments
+// receives an argument which does not name a subcommand.
+type UnknownCommandError struct {
+	// Command is the command which was parsed when the unknown name was found.
+	Command *Command
+	// Name is the argument found instead of a command name.
+	Name string
+	// Suggestions holds the names of similar subcommands. See Command.SuggestionsFor.
+	Suggestions []string
+}
+
+func (e *UnknownCommandError) Error() string {
+	if len(e.Suggestions) == 0 {
+		return fmt.Sprintf("unknown command %q", e.Name)
+	}
+
+	// Quote suggestions.
+	quoted := make([]string, len(e.Suggestions))
+	for i, suggestion := range e.Suggestions {
+		quoted[i] = strconv.Quote(suggestion)
+	}
+
+	return fmt.Sprintf("unknown command %q, did you mean %s?", e.Name, strings.Join(quoted, " or "))
+}
+
+// A FlagParseError is returned by Parse when pflag fails
+// to parse the flags supplied to a command.
+type FlagParseError struct {
+	// Command is the command whose flags failed to parse.
+	Command *Command
+	// Err is the error returned by pflag.
+	Err error
+}
+
+func (e *FlagParseError) Error() string {
+	return fmt.Sprintf("command %q: %v", e.Command.GetCommandPath(), e.Err)
+}
+
+func (e *FlagParseError) Unwrap() error {
+	return e.Err
+}
+
+// A FlagConstraintError is returned by Parse when required flags are not set
+// or flag group constraints are violated. See Command.MarkFlagRequired.
+type FlagConstraintError struct {
+	// Command is the last active command.
+	Command *Command
+	// Violations describes each violated constraint.
+	Violations []string
+}
+
+func (e *FlagConstraintError) Error() string {
+	return fmt.Sprintf("command %q: %s", e.Command.GetCommandPath(), strings.Join(e.Violations, "; "))
+}
+
+// A FlagValidationError is returned by Parse when a validator
+// rejects a flag value. See Command.ValidateFlag.
+type FlagValidationError struct {
+	// Command is the command which registered the validator.
+	Command *Command
+	// Flag is the name of the flag.
+	Flag string
+	// Value is the rejected value.
+	Value string
+	// Err is the error returned by the validator.
+	Err error
+}
+
+func (e *FlagValidationError) Error() string {
+	return fmt.Sprintf("command %q: invalid value %q for flag \"--%s\": %v", e.Command.GetCommandPath(), e.Value, e.Flag, e.Err)
+}
+
+func (e *FlagValidationError) Unwrap() error {
+	return e.Err
+}
+
+// A ResponseFileError is returned by Parse when a response file
+// cannot be read or expanded. See Command.SetResponseFiles.
+type ResponseFileError struct {
+	// Path is the path of the response file.
+	Path string
+	// Err is the error which occurred.
+	Err error
+}
+
+func (e *ResponseFileError) Error() string {
+	return fmt.Sprintf("response file %q: %v", e.Path, e.Err)
+}
+
+func (e *ResponseFileError) Unwrap() error {
+	return e.Err
+}
+
+// A PluginError is returned by Parse in no-exit mode when a plugin
+// fails to start or exits with an error. See Command.EnablePlugins.
+type PluginError struct {
+	// Command is the command which found the plugin.
+	Command *Command
+	// Name is the command name of the plugin.
+	Name string
+	// Path is the path of the plugin executable.
+	Path string
+	// Err is the error returned by os/exec, e.g. an *exec.ExitError.
+	Err error
+}
+
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("command %q: plugin %q: %v", e.Command.GetCommandPath(), e.Name, e.Err)
+}
+
+func (e *PluginError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,235 @@
+package cflag
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// A ManHeader holds the values of the title line of generated man pages.
+// See GenManTree.
+type ManHeader struct {
+	// Title is the page title. Defaults to the upper-cased page name, e.g. "APP-FOO".
+	Title string
+	// Section is the manual section. Defaults to "1".
+	Section string
+	// Date is the date of the page. Defaults to the time in $SOURCE_DATE_EPOCH
+	// if set, or to the current time otherwise.
+	Date *time.Time
+	// Source is the source of the command, e.g. "App 1.2.0".
+	Source string
+	// Manual is the title of the manual, e.g. "App Manual".
+	Manual string
+}
+
+// GenManTree writes a troff man page for root and each command below root
+// to dir, skipping hidden commands which are not deprecated. The pages are named after the command paths, e.g. "app-foo-bar.1"
+// for the command "app foo bar". header may be nil to use the defaults.
+func GenManTree(root *Command, header *ManHeader, dir string) error {
+	if header == nil {
+		header = new(ManHeader)
+	}
+
+	return walkDoc(root, func(cmd *Command) error {
+		section := manSection(header)
+		f, err := os.Create(filepath.Join(dir, manPageName(cmd)+"."+section))
+		if err != nil {
+			return err
+		}
+		if err := GenMan(cmd, header, f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// GenMan writes a troff man page for the command to w.
+// header may be nil to use the defaults. See GenManTree.
+func GenMan(cmd *Command, header *ManHeader, w io.Writer) error {
+	if header == nil {
+		header = new(ManHeader)
+	}
+	name := manPageName(cmd)
+	section := manSection(header)
+	buf := new(bytes.Buffer)
+
+	// Add title line.
+	title := header.Title
+	if len(title) == 0 {
+		title = strings.ToUpper(name)
+	}
+	date := time.Now()
+	if header.Date != nil {
+		date = *header.Date
+	} else if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		date = time.Unix(epoch, 0).UTC()
+	}
+	_, _ = fmt.Fprintf(buf, ".TH %s %s %s %s %s\n", manQuote(title), manQuote(section),
+		manQuote(date.Format("Jan 2006")), manQuote(header.Source), manQuote(header.Manual))
+
+	// Add name, synopsis and description.
+	_, _ = fmt.Fprintf(buf, ".SH NAME\n%s \\- %s\n", manEscape(name), manEscape(cmd.usage))
+	if cmd.deprecated {
+		_, _ = fmt.Fprintf(buf, ".SH DEPRECATED\nThe command \\fB%s\\fP is deprecated and may be removed in a future version.\n", manEscape(cmd.GetCommandPath()))
+	}
+	_, _ = fmt.Fprintf(buf, ".SH SYNOPSIS\n\\fB%s\\fP%s\n", manEscape(cmd.GetCommandPath()), manEscape(synopsisArgs(cmd)))
+	if len(cmd.description) > 0 {
+		_, _ = fmt.Fprintf(buf, ".SH DESCRIPTION\n%s\n", manEscape(cmd.description))
+	}
+
+	// Add flags.
+	writeManFlags(buf, "OPTIONS", cmd.LocalFlags())
+	writeManFlags(buf, "OPTIONS INHERITED FROM PARENT COMMANDS", cmd.InheritedFlags())
+
+	// Add references to the parent and child commands.
+	var refs []string
+	if cmd.parent != nil {
+		refs = append(refs, fmt.Sprintf("\\fB%s\\fP(%s)", manEscape(manPageName(cmd.parent)), section))
+	}
+	for _, subCmd := range docCommands(cmd) {
+		refs = append(refs, fmt.Sprintf("\\fB%s\\fP(%s)", manEscape(manPageName(subCmd)), section))
+	}
+	if len(refs) > 0 {
+		_, _ = fmt.Fprintf(buf, ".SH SEE ALSO\n%s\n", strings.Join(refs, ", "))
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeManFlags writes a man page section titled title
+// containing the visible flags of flags to buf.
+func writeManFlags(buf *bytes.Buffer, title string, flags *flag.FlagSet) {
+	if !flags.HasAvailableFlags() {
+		return
+	}
+
+	_, _ = fmt.Fprintf(buf, ".SH %s\n", title)
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Hidden || len(f.Deprecated) > 0 {
+			return
+		}
+
+		buf.WriteString(".TP\n")
+		if len(f.Shorthand) > 0 && len(f.ShorthandDeprecated) == 0 {
+			_, _ = fmt.Fprintf(buf, "\\fB\\-%s\\fP, ", manEscape(f.Shorthand))
+		}
+		_, _ = fmt.Fprintf(buf, "\\fB\\-\\-%s\\fP", manEscape(f.Name))
+		varName, usage := flag.UnquoteUsage(f)
+		if len(varName) > 0 {
+			_, _ = fmt.Fprintf(buf, "=\\fI%s\\fP", manEscape(varName))
+		}
+		buf.WriteString("\n")
+		if !isZeroDefault(f) {
+			usage += " (default " + flagDefault(f) + ")"
+		}
+		_, _ = fmt.Fprintf(buf, "%s\n", manEscape(usage))
+	})
+}
+
+// synopsisArgs returns the part of the synopsis of c following
+// the command path, e.g. " [flags] [command] <file>".
+func synopsisArgs(c *Command) string {
+	var res string
+	if c.LocalFlags().HasAvailableFlags() || c.InheritedFlags().HasAvailableFlags() {
+		res += " [flags]"
+	}
+	if len(docCommands(c)) > 0 {
+		res += " [command]"
+	}
+	return res + strings.TrimPrefix(c.Synopsis(), c.GetCommandPath())
+}
+
+// walkDoc calls fn for root and each command below root to document.
+// See docCommands.
+func walkDoc(root *Command, fn func(cmd *Command) error) error {
+	if err := fn(root); err != nil {
+		return err
+	}
+	for _, cmd := range docCommands(root) {
+		if err := walkDoc(cmd, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// docCommands returns the subcommands of c to document, i.e. all commands
+// which are not hidden or hidden because they are deprecated.
+func docCommands(c *Command) []*Command {
+	return filterSlice(c.commands, func(cmd *Command) bool {
+		return !cmd.hidden || cmd.deprecated
+	})
+}
+
+// isZeroDefault reports whether the default value of f is the zero value
+// of its type, so that it is omitted from generated documentation.
+func isZeroDefault(f *flag.Flag) bool {
+	switch f.DefValue {
+	case "", "false", "0", "[]", "<nil>", "0s":
+		return true
+	}
+	return false
+}
+
+// flagDefault returns the default value of f, quoted for string flags.
+// The entries of map values are sorted, as pflag lists them in random order.
+func flagDefault(f *flag.Flag) string {
+	if f.Value.Type() == "string" {
+		return strconv.Quote(f.DefValue)
+	}
+	if strings.HasPrefix(f.Value.Type(), "stringTo") {
+		if entries, err := splitDefault(f.DefValue); err == nil {
+			sort.Strings(entries)
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			_ = w.Write(entries)
+			w.Flush()
+			return "[" + strings.TrimSuffix(buf.String(), "\n") + "]"
+		}
+	}
+	return f.DefValue
+}
+
+// manPageName returns the command path of c joined by hyphens, e.g. "app-foo-bar".
+func manPageName(c *Command) string {
+	return strings.ReplaceAll(c.GetCommandPath(), " ", "-")
+}
+
+// manSection returns the manual section of header, defaulting to "1".
+func manSection(header *ManHeader) string {
+	if len(header.Section) == 0 {
+		return "1"
+	}
+	return header.Section
+}
+
+// manEscape escapes backslashes and hyphens in s for troff
+// and protects lines starting with control characters.
+func manEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// manQuote returns s escaped and enclosed in double quotes
+// for use as a troff macro argument.
+func manQuote(s string) string {
+	return `"` + strings.ReplaceAll(manEscape(s), `"`, `""`) + `"`
+}
@@ -0,0 +1,63 @@
+package cflag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenManTree(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	ctx.cmdWorld.MarkDeprecated()
+	cmdHidden, _ := Cmd("hidden", "Hidden command.", nil)
+	cmdHidden.MarkHidden()
+	root := &command
+	root.flags = ctx.flags
+	ctx.flags.StringP("name", "n", "world", "Name to greet.")
+	ctx.flags.StringToString("label", map[string]string{"b": "x,y", "a": "z"}, "Labels.")
+
+	// Generate man pages.
+	dir := t.TempDir()
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	a.NoError(GenManTree(root, &ManHeader{Date: &date, Source: "App 1.0", Manual: "App Manual"}, dir))
+
+	// Check generated files.
+	prog := manPageName(root)
+	entries, err := os.ReadDir(dir)
+	a.NoError(err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	a.ElementsMatch([]string{prog + ".1", prog + "-foo.1", prog + "-foo-bar.1", prog + "-world.1", prog + "-types.1"}, names)
+
+	// Check page content.
+	data, err := os.ReadFile(filepath.Join(dir, prog+".1"))
+	a.NoError(err)
+	page := string(data)
+	t.Log(page)
+	a.Contains(page, `.TH "`)
+	a.Contains(page, `"Mar 2024" "App 1.0" "App Manual"`)
+	a.Contains(page, ".SH DESCRIPTION\ncflag test application.\n")
+	a.Contains(page, ".SH OPTIONS\n")
+	a.Contains(page, "\\fB\\-\\-test0\\fP=\\fIint\\fP\nTest 0.\n")
+	a.Contains(page, "\\fB\\-n\\fP, \\fB\\-\\-name\\fP=\\fIstring\\fP\nName to greet. (default \"world\")\n")
+	a.Contains(page, "Labels. (default [a=z,\"b=x,y\"])\n")
+	a.Contains(page, ".SH SEE ALSO\n")
+	a.NotContains(page, "hidden")
+
+	data, err = os.ReadFile(filepath.Join(dir, prog+"-foo-bar.1"))
+	a.NoError(err)
+	page = string(data)
+	t.Log(page)
+	a.Contains(page, ".SH NAME\n"+manEscape(prog+"-foo-bar")+" \\- Bar command.\n")
+	a.Contains(page, ".SH SEE ALSO\n\\fB"+manEscape(prog+"-foo")+"\\fP(1)\n")
+
+	data, err = os.ReadFile(filepath.Join(dir, prog+"-world.1"))
+	a.NoError(err)
+	a.Contains(string(data), ".SH DEPRECATED\n")
+}
@@ -0,0 +1,179 @@
+package cflag
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// PluginCommandEnv is the environment variable passed to plugins,
+// holding the command path of the plugin, e.g. "app foo".
+// See Command.EnablePlugins.
+const PluginCommandEnv = "CFLAG_COMMAND_PATH"
+
+// A Plugin describes an external command found on $PATH.
+// See Command.EnablePlugins.
+type Plugin struct {
+	// Name is the command name of the plugin.
+	Name string
+	// Path is the path of the plugin executable.
+	Path string
+}
+
+// EnablePlugins enables external plugin commands for the command. When the
+// first positional argument supplied to the command does not name a subcommand,
+// but an executable named "<prefix>-<name>" is found on $PATH, the executable
+// is run with the remaining arguments instead of executing the callback,
+// like "git foo" runs "git-foo". The command path of the plugin is passed
+// in the environment variable PluginCommandEnv. Flags supplied before
+// the plugin name are parsed by the command. Plugins are not looked up
+// for commands declaring positional arguments, and plugin names must neither
+// contain path separators nor start with a dot.
+//
+// After the plugin exits, Parse exits the application with the exit code
+// of the plugin. In no-exit mode, Parse returns ErrPluginExecuted, or a
+// *PluginError if the plugin failed. See SetNoExit.
+//
+// Discovered plugins are listed in the "Plugin Commands" section of the help page.
+func (c *Command) EnablePlugins(prefix string) *Command {
+	c.plugins = prefix
+	c.pluginList = nil
+	return c
+}
+
+// EnablePlugins enables external plugin commands for the application.
+// See Command.EnablePlugins.
+func EnablePlugins(prefix string) *Command {
+	command.EnablePlugins(prefix)
+	return &command
+}
+
+// Plugins returns the plugins found on $PATH, sorted by name.
+// Plugins named like a subcommand are skipped, and for plugins found
+// in several directories, the first one on $PATH is used.
+// All directories on $PATH are read on the first call only,
+// later calls return the same plugins.
+func (c *Command) Plugins() []Plugin {
+	if len(c.plugins) == 0 {
+		return nil
+	}
+	if c.pluginList != nil {
+		return slices.Clone(c.pluginList)
+	}
+
+	found := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), c.plugins+"-")
+			if !ok || !validPluginName(name) || c.Lookup(name) != nil {
+				continue
+			}
+			if _, ok := found[name]; ok {
+				continue
+			}
+			if path, err := exec.LookPath(filepath.Join(dir, entry.Name())); err == nil {
+				found[name] = path
+			}
+		}
+	}
+
+	plugins := make([]Plugin, 0, len(found))
+	for name, path := range found {
+		plugins = append(plugins, Plugin{Name: name, Path: path})
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	c.pluginList = plugins
+	return slices.Clone(plugins)
+}
+
+// validPluginName reports whether name may name a plugin. Names containing
+// path separators or starting with a dot are rejected, so that plugins are
+// only looked up on $PATH and not relative to the working directory.
+func validPluginName(name string) bool {
+	return len(name) > 0 && name[0] != '.' && !strings.ContainsAny(name, "/"+string(os.PathSeparator))
+}
+
+// PluginUsagesWrapped returns a string containing the names and paths
+// of all plugins found for this command.
+// Wrapped to cols columns (0 for no wrapping).
+func (c *Command) PluginUsagesWrapped(cols int) string {
+	var rows [][2]string
+	for _, plugin := range c.Plugins() {
+		rows = append(rows, [2]string{plugin.Name, plugin.Path})
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	return usageTable(rows, cols)
+}
+
+// findPlugin returns the index of the first positional argument and the path
+// of the plugin it names, or -1 if it names a subcommand or no plugin.
+// Values consumed by flags defined in flagSets are skipped. Commands
+// declaring positional arguments do not look up plugins.
+func (c *Command) findPlugin(arguments []string, flagSets []*flag.FlagSet) (int, string) {
+	if len(c.plugins) == 0 || len(c.args) > 0 || c.argRange != nil {
+		return -1, ""
+	}
+
+	for iArg := 0; iArg < len(arguments); iArg++ {
+		arg := arguments[iArg]
+
+		switch {
+		case arg == "--":
+			return -1, ""
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// Skip the value consumed by the flag.
+			if valueFlag(arg, flagSets) != nil {
+				iArg++
+			}
+		default:
+			if c.matchCommand(arg) != nil || !validPluginName(arg) {
+				return -1, ""
+			}
+			path, err := exec.LookPath(c.plugins + "-" + arg)
+			if err != nil {
+				return -1, ""
+			}
+			return iArg, path
+		}
+	}
+
+	return -1, ""
+}
+
+// execPlugin runs the plugin name at path with arguments and
+// exits the application unless the no-exit mode is enabled.
+func (c *Command) execPlugin(name, path string, arguments []string, noExit bool) error {
+	cmd := exec.CommandContext(c.Context(), path, arguments...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), PluginCommandEnv+"="+c.GetCommandPath()+" "+name)
+	err := cmd.Run()
+
+	if !noExit {
+		if cmd.ProcessState == nil {
+			// The plugin could not be started.
+			_, _ = fmt.Fprintln(c.out(), err)
+			os.Exit(1)
+		}
+		os.Exit(cmd.ProcessState.ExitCode())
+	}
+	if err != nil {
+		return &PluginError{Command: c, Name: name, Path: path, Err: err}
+	}
+	return ErrPluginExecuted
+}
@@ -0,0 +1,112 @@
+package cflag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestPlugin writes a shell script named name to dir, which writes
+// its arguments and the command path to the file out and exits with code.
+func writeTestPlugin(a *assert.Assertions, dir, name, out, code string) {
+	script := "#!/bin/sh\necho \"$*|$" + PluginCommandEnv + "\" > '" + out + "'\nexit " + code + "\n"
+	a.NoError(os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755))
+}
+
+func TestPlugins(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	SetNoExit()
+	EnablePlugins("app")
+
+	// Create plugins on $PATH.
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	writeTestPlugin(a, dir, "app-hello", out, "0")
+	writeTestPlugin(a, dir, "app-fail", out, "3")
+	writeTestPlugin(a, dir, "app-foo", out, "0")
+	a.NoError(os.WriteFile(filepath.Join(dir, "app-notexec"), nil, 0o644))
+	t.Setenv("PATH", dir)
+
+	// Check discovered plugins and help output.
+	a.Equal([]Plugin{
+		{Name: "fail", Path: filepath.Join(dir, "app-fail")},
+		{Name: "hello", Path: filepath.Join(dir, "app-hello")},
+	}, command.Plugins())
+	output := CommandUsage()
+	t.Log(output)
+	a.Contains(output, "Plugin Commands:\n  fail ")
+
+	// Run plugin. Flags before the plugin name are parsed by the command.
+	err := Parse(append(ctx.arguments, "--test0", "10", "hello", "--name", "world", "foo"), ctx.flags)
+	a.ErrorIs(err, ErrPluginExecuted)
+	a.Equal(10, *ctx.paramTest0)
+	data, _ := os.ReadFile(out)
+	a.Equal("--name world foo|"+command.GetCommandPath()+" hello\n", string(data))
+
+	// Failing plugin.
+	ctx = buildTestContext()
+	SetNoExit()
+	EnablePlugins("app")
+	err = Parse(append(ctx.arguments, "fail"), ctx.flags)
+	t.Log(err)
+	var pluginErr *PluginError
+	if a.ErrorAs(err, &pluginErr) {
+		a.Equal("fail", pluginErr.Name)
+	}
+
+	// Subcommands take precedence over plugins.
+	ctx = buildTestContext()
+	SetNoExit()
+	EnablePlugins("app")
+	a.Nil(Parse(append(ctx.arguments, "foo", "--test1", "11"), ctx.flags))
+	a.Equal(11, *ctx.paramTest1)
+}
+
+func TestPluginLookup(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	SetNoExit()
+	EnablePlugins("app")
+
+	// Create a plugin on $PATH and an executable in the working directory.
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	writeTestPlugin(a, dir, "app-hello", out, "0")
+	t.Setenv("PATH", dir)
+	wd, err := os.Getwd()
+	a.NoError(err)
+	a.NoError(os.Chdir(dir))
+	defer func() {
+		_ = os.Chdir(wd)
+	}()
+	a.NoError(os.Mkdir("app-x", 0o755))
+	writeTestPlugin(a, dir, "evil", out, "0")
+
+	// Names with path separators or a leading dot are not looked up.
+	for _, name := range []string{"x/../evil", "./evil", ".hidden"} {
+		a.NoError(ResetState())
+		err = Parse(append(ctx.arguments, name), ctx.flags)
+		var cmdErr *UnknownCommandError
+		a.ErrorAs(err, &cmdErr, name)
+		a.NoFileExists(out, name)
+	}
+
+	// Commands declaring positional arguments do not run plugins.
+	cmdGreet, _ := Cmd("greet", "Greet command.", nil)
+	a.NoError(cmdGreet.AddArg("name", "Name."))
+	cmdGreet.EnablePlugins("app")
+	a.NoError(ResetState())
+	a.Nil(Parse(append(ctx.arguments, "greet", "hello"), ctx.flags))
+	a.Equal("hello", cmdGreet.GetArg("name"))
+	a.NoFileExists(out)
+
+	// Discovered plugins are cached.
+	a.Equal([]Plugin{{Name: "hello", Path: filepath.Join(dir, "app-hello")}}, command.Plugins())
+	writeTestPlugin(a, dir, "app-later", out, "0")
+	a.Len(command.Plugins(), 1)
+	EnablePlugins("app")
+	a.Len(command.Plugins(), 2)
+}
@@ -0,0 +1,175 @@
+package cflag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// The maximum nesting depth of response files. See Command.SetResponseFiles.
+const maxResponseFileDepth = 16
+
+// SetResponseFiles enables the expansion of response files for the command
+// and all its subcommands. Parse replaces each argument of the form "@path"
+// following the command name with the arguments read from the file at path
+// before resolving the subcommands, so that response files may contain
+// subcommand names as well. Arguments after the "--" terminator are not
+// expanded.
+//
+// Arguments in response files are separated by whitespace and may be quoted
+// like in a POSIX shell: single quotes preserve all characters, double quotes
+// and backslashes escape whitespace and quotes. Lines starting with "#" are
+// comments. Response files may include further response files, where relative
+// paths are resolved against the directory of the including file.
+func (c *Command) SetResponseFiles() *Command {
+	c.respFiles = true
+	return c
+}
+
+// SetResponseFiles enables the expansion of response files.
+// See Command.SetResponseFiles.
+func SetResponseFiles() *Command {
+	command.SetResponseFiles()
+	return &command
+}
+
+// isResponseFiles reports whether response file expansion is enabled
+// for c, one of its parent commands or the global command.
+func (c *Command) isResponseFiles() bool {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if cmd.respFiles {
+			return true
+		}
+	}
+	return command.respFiles
+}
+
+// expandResponseFiles returns arguments with each "@path" argument
+// replaced by the arguments read from the file at path.
+func expandResponseFiles(arguments []string) ([]string, error) {
+	return expandResponseFilesDir(arguments, "", nil)
+}
+
+// expandResponseFilesDir expands the response files in arguments, resolving
+// relative paths against dir. stack holds the absolute paths of the
+// response files currently being expanded.
+func expandResponseFilesDir(arguments []string, dir string, stack []string) ([]string, error) {
+	var res []string
+
+	for i, arg := range arguments {
+		if arg == "--" {
+			return append(res, arguments[i:]...), nil
+		}
+		if len(arg) < 2 || arg[0] != '@' {
+			res = append(res, arg)
+			continue
+		}
+
+		// Resolve the path and check for cycles.
+		path := arg[1:]
+		if len(dir) > 0 && !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, &ResponseFileError{Path: path, Err: err}
+		}
+		if slices.Contains(stack, absPath) {
+			return nil, &ResponseFileError{Path: path, Err: fmt.Errorf("include cycle detected")}
+		}
+		if len(stack) >= maxResponseFileDepth {
+			return nil, &ResponseFileError{Path: path, Err: fmt.Errorf("maximum include depth of %d exceeded", maxResponseFileDepth)}
+		}
+
+		// Read and expand the arguments of the file.
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, &ResponseFileError{Path: path, Err: err}
+		}
+		fileArgs, err := splitResponseFile(string(data))
+		if err != nil {
+			return nil, &ResponseFileError{Path: path, Err: err}
+		}
+		fileArgs, err = expandResponseFilesDir(fileArgs, filepath.Dir(path), append(stack, absPath))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, fileArgs...)
+	}
+
+	return res, nil
+}
+
+// splitResponseFile splits the content of a response file into arguments,
+// skipping comment lines. See Command.SetResponseFiles.
+func splitResponseFile(s string) ([]string, error) {
+	var lines []string
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines = append(lines, line)
+		}
+	}
+	return splitArgs(strings.Join(lines, ""))
+}
+
+// splitArgs splits s into arguments separated by whitespace,
+// respecting single quotes, double quotes and backslash escapes
+// like a POSIX shell.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			// Escaped newlines continue the line.
+			if r != '\n' {
+				arg.WriteRune(r)
+			}
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\\' && (quote == 0 || quote == '"'):
+			inArg = true
+			escaped = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			inArg = true
+			quote = r
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			inArg = true
+			arg.WriteRune(r)
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("unterminated escape sequence")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
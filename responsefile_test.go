@@ -0,0 +1,86 @@
+package cflag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseFiles(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	SetResponseFiles()
+
+	// Write nested response files.
+	dir := t.TempDir()
+	a.NoError(os.WriteFile(filepath.Join(dir, "args.txt"), []byte("# Global flags.\n--test0 10\n\nfoo @nested/foo.txt\n"), 0o644))
+	a.NoError(os.MkdirAll(filepath.Join(dir, "nested"), 0o755))
+	a.NoError(os.WriteFile(filepath.Join(dir, "nested", "foo.txt"), []byte("--test1 '11'\n  # Comment.\nbar \"--test2\"\\\n 12\n"), 0o644))
+
+	// Run cflag parser.
+	a.Nil(Parse(append(ctx.arguments, "@"+filepath.Join(dir, "args.txt"), "--", "@literal"), ctx.flags))
+
+	// Check flag values and positional arguments.
+	a.Equal(10, *ctx.paramTest0)
+	a.Equal(11, *ctx.paramTest1)
+	a.Equal(12, *ctx.paramTest2)
+	a.True(ctx.cmdFooBar.IsActive())
+	a.Equal([]string{"@literal"}, ctx.flagsFooBar.Args())
+}
+
+func TestResponseFilesSubCommand(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	ctx.cmdFoo.SetResponseFiles()
+
+	// Write response file for the subcommand.
+	dir := t.TempDir()
+	a.NoError(os.WriteFile(filepath.Join(dir, "foo.txt"), []byte("--test1 11 bar --test2 12\n"), 0o644))
+
+	// Run cflag parser.
+	a.Nil(Parse(append(ctx.arguments, "--test0", "10", "foo", "@"+filepath.Join(dir, "foo.txt"), "--", "@literal"), ctx.flags))
+
+	// Check flag values and positional arguments.
+	a.Equal(10, *ctx.paramTest0)
+	a.Equal(11, *ctx.paramTest1)
+	a.Equal(12, *ctx.paramTest2)
+	a.True(ctx.cmdFooBar.IsActive())
+	a.Equal([]string{"@literal"}, ctx.flagsFooBar.Args())
+
+	// Arguments of the parent command are not expanded.
+	ctx = buildTestContext()
+	ctx.cmdFoo.SetResponseFiles()
+	a.Error(Parse(append(ctx.arguments, "@"+filepath.Join(dir, "foo.txt")), ctx.flags))
+}
+
+func TestResponseFilesInvalid(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+
+	// Include cycle.
+	a.NoError(os.WriteFile(filepath.Join(dir, "a.txt"), []byte("@b.txt"), 0o644))
+	a.NoError(os.WriteFile(filepath.Join(dir, "b.txt"), []byte("@a.txt"), 0o644))
+	_, err := expandResponseFiles([]string{"@" + filepath.Join(dir, "a.txt")})
+	var respErr *ResponseFileError
+	a.ErrorAs(err, &respErr)
+	a.ErrorContains(err, "include cycle")
+
+	// Missing file.
+	_, err = expandResponseFiles([]string{"@" + filepath.Join(dir, "missing.txt")})
+	a.ErrorIs(err, os.ErrNotExist)
+
+	// Unterminated quote.
+	a.NoError(os.WriteFile(filepath.Join(dir, "quote.txt"), []byte("'abc"), 0o644))
+	_, err = expandResponseFiles([]string{"@" + filepath.Join(dir, "quote.txt")})
+	a.ErrorContains(err, "unterminated quote")
+}
+
+func TestSplitArgs(t *testing.T) {
+	a := assert.New(t)
+
+	args, err := splitArgs(` a  'b c' "d \"e\"" f\ g 'h\' "" `)
+	a.NoError(err)
+	a.Equal([]string{"a", "b c", `d "e"`, "f g", `h\`, ""}, args)
+}
@@ -0,0 +1,268 @@
+package cflag
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+// The name of the built-in shell command. See Command.AddShellCommand.
+const shellCommandName = "shell"
+
+// RunShell runs an interactive shell for the command tree of the top-level
+// command of c. It reads command lines from in, splits them into arguments
+// like a POSIX shell and parses them like the command line arguments passed
+// to Parse, executing the callbacks of the active commands. The state of the
+// command tree is reset before each line. Errors are written to out and do
+// not end the shell. While the shell runs, out is used as the output of all
+// commands of the tree, e.g. for help pages and deprecation warnings.
+//
+// The built-in commands "help [command...]" and "exit" print the help page of
+// a command and end the shell. When in is a terminal, the shell supports
+// editing the line, the history of previous lines and tab completion of
+// commands, flags and values. RunShell returns when "exit" is entered or in
+// reaches EOF, e.g. when Ctrl-D or Ctrl-C is pressed. When ctx is cancelled,
+// RunShell returns its error before reading the next line.
+//
+// Each line is executed with a context derived from ctx. With
+// SetCancelOnSignal, a signal cancels the context of the running line only.
+func (c *Command) RunShell(ctx context.Context, in io.Reader, out io.Writer) error {
+	root := c
+	for root.parent != nil {
+		root = root.parent
+	}
+
+	// Return errors instead of exiting while running the shell.
+	noExit := root.noExit
+	root.noExit = true
+	defer func() {
+		root.noExit = noExit
+	}()
+
+	// Write help pages, warnings and usage errors to out.
+	defer root.setTreeOutput(out)()
+
+	// Read lines from a terminal or line by line.
+	var readLine func() (string, error)
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fd := int(f.Fd())
+		t := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{in, out}, root.GetCommandPath()+"> ")
+		if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+			_ = t.SetSize(width, height)
+		}
+		t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+			if key != '\t' {
+				return "", 0, false
+			}
+			newLine, newPos, candidates := root.shellComplete(line, pos)
+			if len(candidates) > 0 {
+				_, _ = fmt.Fprintln(t, strings.Join(candidates, "  "))
+			}
+			return newLine, newPos, true
+		}
+		readLine = func() (string, error) {
+			state, err := term.MakeRaw(fd)
+			if err != nil {
+				return "", err
+			}
+			defer func() {
+				_ = term.Restore(fd, state)
+			}()
+			return t.ReadLine()
+		}
+	} else {
+		scanner := bufio.NewScanner(in)
+		readLine = func() (string, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, err := readLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if exit := root.execShellLine(ctx, line, out); exit {
+			return nil
+		}
+	}
+}
+
+// AddShellCommand adds the subcommand "shell" to c, which runs an interactive
+// shell for the command tree using os.Stdin and os.Stdout. See Command.RunShell.
+func (c *Command) AddShellCommand() (*Command, error) {
+	cmd := NewCommand(shellCommandName, "Run an interactive shell.", nil)
+	cmd.SetDescription(`Enter commands without the application name, "help [command...]" for help or "exit" to quit.`)
+	cmd.SetContextCallback(func(ctx context.Context, command *Command, flags *flag.FlagSet) error {
+		return command.RunShell(ctx, os.Stdin, os.Stdout)
+	})
+
+	if err := c.AddCommand(cmd); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// AddShellCommand adds the subcommand "shell" to the application,
+// which runs an interactive shell. See Command.AddShellCommand.
+func AddShellCommand() (*Command, error) {
+	return command.AddShellCommand()
+}
+
+// execShellLine executes the command line read by the shell and
+// writes errors to out. It reports whether the shell must exit.
+func (c *Command) execShellLine(ctx context.Context, line string, out io.Writer) bool {
+	args, err := splitArgs(line)
+	if err != nil {
+		_, _ = fmt.Fprintln(out, err)
+		return false
+	}
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "exit", "quit":
+		return true
+	case "help":
+		cmd := c.Find(strings.Join(args[1:], " "))
+		if cmd == nil {
+			_, _ = fmt.Fprintf(out, "unknown command %q\n", strings.Join(args[1:], " "))
+			return false
+		}
+		_, _ = fmt.Fprint(out, cmd.CommandUsage())
+		return false
+	}
+
+	// Parse the line using the application name as first argument.
+	name := c.name
+	if len(name) == 0 {
+		name = c.GetCommandPath()
+	}
+	// Flags which cannot be reset keep their values, report them but
+	// execute the line anyway.
+	if err := c.ResetState(); err != nil {
+		_, _ = fmt.Fprintln(out, err)
+	}
+	// Run the line with its own context, so that cancelling a line,
+	// e.g. by a signal with SetCancelOnSignal, does not affect later lines.
+	lineCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err = c.ExecuteContext(lineCtx, append([]string{name}, args...))
+	if err != nil && !errors.Is(err, ErrHelpRequested) && !errors.Is(err, ErrPluginExecuted) {
+		_, _ = fmt.Fprintln(out, err)
+	}
+	return false
+}
+
+// setTreeOutput sets the output of c and all its subcommands to output.
+// It returns a function restoring the previous outputs.
+func (c *Command) setTreeOutput(output io.Writer) func() {
+	prevOutput := c.output
+	c.output = output
+	restores := make([]func(), 0, len(c.commands))
+	for _, cmd := range c.commands {
+		restores = append(restores, cmd.setTreeOutput(output))
+	}
+
+	return func() {
+		c.output = prevOutput
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
+
+// shellComplete completes the argument ending at pos in the shell line.
+// It returns the completed line and position, and the candidates to list
+// if the argument cannot be completed unambiguously.
+func (c *Command) shellComplete(line string, pos int) (string, int, []string) {
+	prefix, suffix := line[:pos], line[pos:]
+	args, err := splitArgs(prefix)
+	if err != nil {
+		return line, pos, nil
+	}
+
+	// Split the word to complete from the preceding arguments.
+	toComplete := ""
+	if len(args) > 0 && len(strings.TrimRight(prefix, " \t")) == len(prefix) {
+		toComplete = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	start := strings.LastIndexAny(prefix, " \t") + 1
+
+	// Complete the built-in commands or resolve the candidates.
+	var values []string
+	var directive CompletionDirective
+	if len(args) == 0 {
+		for _, name := range []string{"exit", "help"} {
+			if strings.HasPrefix(name, toComplete) {
+				values = append(values, name)
+			}
+		}
+	} else if args[0] == "help" {
+		args = args[1:]
+	}
+	var completions []Completion
+	completions, directive = c.Complete(append(args, toComplete))
+	if directive&CompletionError != 0 {
+		return line, pos, nil
+	}
+	for _, completion := range completions {
+		if strings.HasPrefix(completion.Value, toComplete) {
+			values = append(values, completion.Value)
+		}
+	}
+
+	switch len(values) {
+	case 0:
+		return line, pos, nil
+	case 1:
+		// Replace the word with the single candidate.
+		value := values[0]
+		if strings.ContainsAny(value, " \t'\"\\") {
+			value = shQuote(value)
+		}
+		if directive&CompletionNoSpace == 0 {
+			value += " "
+		}
+		newPrefix := prefix[:start] + value
+		return newPrefix + suffix, len(newPrefix), nil
+	}
+
+	// Extend the word to the common prefix of the candidates.
+	common := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(toComplete) && !strings.ContainsAny(common, " \t'\"\\") {
+		newPrefix := prefix[:start] + common
+		return newPrefix + suffix, len(newPrefix), nil
+	}
+	return line, pos, values
+}
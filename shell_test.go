@@ -0,0 +1,151 @@
+package cflag
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunShell(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	command.flags = ctx.flags
+
+	// Record the flag values of each callback execution.
+	var calls []string
+	ctx.cmdFooBar.SetCallback(func(command *Command, flags *flag.FlagSet) error {
+		calls = append(calls, strings.Join([]string{
+			flags.Lookup("test2").Value.String(),
+			ctx.flagsFoo.Lookup("test1").Value.String(),
+		}, ","))
+		return nil
+	})
+
+	// Run shell.
+	in := strings.NewReader(`foo --test1 11 bar --test2 12
+foo bar
+foo 'bar' --test2 "13"
+
+help foo
+help other
+foo bar --test2 x
+foo bar --help
+world
+exit
+foo bar
+`)
+	ctx.cmdWorld.MarkDeprecated()
+	ctx.cmdFoo.SetOutput(io.Discard)
+	out := new(bytes.Buffer)
+	a.NoError(command.RunShell(context.Background(), in, out))
+	t.Log(out.String())
+
+	// Check executed callbacks and output.
+	a.Equal([]string{"12,11", "2,1", "13,1"}, calls)
+	a.Contains(out.String(), "Foo command description.\nCommands:\n")
+	a.Contains(out.String(), `unknown command "other"`)
+	a.Contains(out.String(), `invalid argument "x" for "--test2"`)
+	a.Contains(out.String(), "--test2 int   Test 2.")
+	a.Contains(out.String(), `Command "world" is deprecated!`)
+	a.False(command.noExit)
+	a.Nil(command.output)
+	a.Equal(io.Discard, ctx.cmdFoo.output)
+}
+
+func TestShellResetError(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	command.flags = ctx.flags
+	paramMap := ctx.flagsFoo.StringToString("map", nil, "Map flag.")
+
+	// Lines are executed although the map flag cannot be reset.
+	var calls []string
+	ctx.cmdFoo.SetCallback(func(command *Command, flags *flag.FlagSet) error {
+		calls = append(calls, (*paramMap)["k"])
+		return nil
+	})
+	out := new(bytes.Buffer)
+	a.NoError(command.RunShell(context.Background(), strings.NewReader("foo --map k=a\nfoo --map k=b\n"), out))
+	t.Log(out.String())
+	a.Equal([]string{"a", "b"}, calls)
+	a.Contains(out.String(), "cannot reset flag 'map'")
+}
+
+func TestShellCancelOnSignal(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not supported")
+	}
+	SetCancelOnSignal()
+
+	// Interrupt the running line and wait for its context to be cancelled.
+	var errs []error
+	ctx.cmdFoo.SetContextCallback(func(ctx context.Context, command *Command, flags *flag.FlagSet) error {
+		process, err := os.FindProcess(os.Getpid())
+		a.NoError(err)
+		a.NoError(process.Signal(os.Interrupt))
+
+		select {
+		case <-ctx.Done():
+			errs = append(errs, ctx.Err())
+		case <-time.After(5 * time.Second):
+			errs = append(errs, nil)
+		}
+		return nil
+	})
+	var shellCtx context.Context
+	ctx.cmdWorld.SetCallback(func(command *Command, flags *flag.FlagSet) error {
+		errs = append(errs, command.Context().Err())
+		a.NoError(shellCtx.Err())
+		return nil
+	})
+
+	// Run the shell from a command executed with a signal handler.
+	cmdShell, _ := Cmd("shell", "Shell command.", nil)
+	cmdShell.SetContextCallback(func(ctx context.Context, command *Command, flags *flag.FlagSet) error {
+		shellCtx = ctx
+		return command.RunShell(ctx, strings.NewReader("foo\nworld\nfoo\nworld\n"), new(bytes.Buffer))
+	})
+	a.NoError(ExecuteContext(context.Background(), append(ctx.arguments, "shell"), ctx.flags))
+
+	// Signals cancel the running line only.
+	a.Equal([]error{context.Canceled, nil, context.Canceled, nil}, errs)
+	a.Nil(command.ctx)
+}
+
+func TestShellComplete(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	command.flags = ctx.flags
+
+	tests := []struct {
+		line       string
+		newLine    string
+		candidates []string
+	}{
+		{"fo", "foo ", nil},
+		{"foo b", "foo bar ", nil},
+		{"he", "help ", nil},
+		{"help w", "help world ", nil},
+		{"types --st", "types --str ", nil},
+		{"x", "x", nil},
+		{"", "", []string{"exit", "help", "foo", "world", "types"}},
+		{"foo --test1 1 b -- x", "foo --test1 1 b -- x", nil},
+	}
+	for _, test := range tests {
+		newLine, newPos, candidates := command.shellComplete(test.line, len(test.line))
+		a.Equal(test.newLine, newLine, test.line)
+		a.Equal(len(test.newLine), newPos, test.line)
+		a.Equal(test.candidates, candidates, test.line)
+		a.False(ctx.cmdFoo.IsActive(), test.line)
+	}
+}
@@ -0,0 +1,392 @@
+package cflag
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// SpecVersion is the version of the command tree specification
+// format written by Command.MarshalSpec.
+const SpecVersion = 1
+
+// A Spec is the machine-readable specification of a command tree.
+// See Command.MarshalSpec.
+type Spec struct {
+	// Version is the format version, see SpecVersion.
+	Version int `json:"version" yaml:"version"`
+	// Command is the specification of the top-level command.
+	Command *CommandSpec `json:"command" yaml:"command"`
+}
+
+// A CommandSpec is the specification of a command and its subcommands.
+type CommandSpec struct {
+	Name        string         `json:"name" yaml:"name"`
+	Usage       string         `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Description string         `json:"description,omitempty" yaml:"description,omitempty"`
+	Aliases     []string       `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Hidden      bool           `json:"hidden,omitempty" yaml:"hidden,omitempty"`
+	Deprecated  bool           `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Args        []*ArgSpec     `json:"args,omitempty" yaml:"args,omitempty"`
+	Flags       []*FlagSpec    `json:"flags,omitempty" yaml:"flags,omitempty"`
+	Commands    []*CommandSpec `json:"commands,omitempty" yaml:"commands,omitempty"`
+}
+
+// An ArgSpec is the specification of a positional argument. See Arg.
+type ArgSpec struct {
+	Name     string `json:"name" yaml:"name"`
+	Usage    string `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Optional bool   `json:"optional,omitempty" yaml:"optional,omitempty"`
+	Variadic bool   `json:"variadic,omitempty" yaml:"variadic,omitempty"`
+}
+
+// A FlagSpec is the specification of a flag. Type is the pflag type name
+// returned by flag.Value.Type, e.g. "int" or "stringSlice", and Default
+// is the default value in its command line representation.
+type FlagSpec struct {
+	Name        string              `json:"name" yaml:"name"`
+	Shorthand   string              `json:"shorthand,omitempty" yaml:"shorthand,omitempty"`
+	Type        string              `json:"type" yaml:"type"`
+	Default     string              `json:"default,omitempty" yaml:"default,omitempty"`
+	Usage       string              `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Persistent  bool                `json:"persistent,omitempty" yaml:"persistent,omitempty"`
+	Hidden      bool                `json:"hidden,omitempty" yaml:"hidden,omitempty"`
+	Deprecated  string              `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Annotations map[string][]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// Spec returns the specification of the command and all its subcommands,
+// including hidden commands and flags. Inherited persistent flags are
+// specified by the parent commands defining them only, and the built-in
+// help flag is omitted.
+func (c *Command) Spec() *Spec {
+	return &Spec{Version: SpecVersion, Command: c.commandSpec()}
+}
+
+// MarshalSpec returns the indented JSON encoding of the specification
+// of the command and all its subcommands. See Command.Spec.
+func (c *Command) MarshalSpec() ([]byte, error) {
+	return json.MarshalIndent(c.Spec(), "", "  ")
+}
+
+// MarshalSpec returns the JSON encoding of the specification
+// of the application. See Command.MarshalSpec.
+func MarshalSpec() ([]byte, error) {
+	return command.MarshalSpec()
+}
+
+// commandSpec returns the specification of the command and its subcommands.
+func (c *Command) commandSpec() *CommandSpec {
+	spec := &CommandSpec{
+		Name:        c.name,
+		Usage:       c.usage,
+		Description: c.description,
+		Aliases:     c.aliases,
+		Hidden:      c.hidden,
+		Deprecated:  c.deprecated,
+	}
+
+	for _, arg := range c.args {
+		spec.Args = append(spec.Args, &ArgSpec{Name: arg.Name, Usage: arg.Usage, Optional: arg.Optional, Variadic: arg.Variadic})
+	}
+
+	c.LocalFlags().VisitAll(func(f *flag.Flag) {
+		if f.Name == "help" {
+			return
+		}
+		spec.Flags = append(spec.Flags, &FlagSpec{
+			Name:        f.Name,
+			Shorthand:   f.Shorthand,
+			Type:        f.Value.Type(),
+			Default:     f.DefValue,
+			Usage:       f.Usage,
+			Persistent:  c.persistent != nil && c.persistent.Lookup(f.Name) == f,
+			Hidden:      f.Hidden,
+			Deprecated:  f.Deprecated,
+			Annotations: specAnnotations(f),
+		})
+	})
+
+	for _, cmd := range c.commands {
+		spec.Commands = append(spec.Commands, cmd.commandSpec())
+	}
+
+	return spec
+}
+
+// specAnnotations returns the annotations of f without the internal
+// annotation set by Command.ResetState.
+func specAnnotations(f *flag.Flag) map[string][]string {
+	if _, ok := f.Annotations[resetAnnotation]; !ok {
+		return f.Annotations
+	}
+	annotations := make(map[string][]string, len(f.Annotations))
+	for key, values := range f.Annotations {
+		if key != resetAnnotation {
+			annotations[key] = values
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// LoadSpec builds a command tree from the JSON or YAML encoded specification
+// read from r, in the format written by Command.MarshalSpec. All pflag types
+// are supported, see FlagSpec. Callbacks can be bound to the loaded commands
+// using Command.Find.
+func LoadSpec(r io.Reader) (*Command, error) {
+	// JSON is a subset of YAML, so that both formats can be decoded at once.
+	var spec Spec
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid specification: %v", err)
+	}
+	if spec.Version < 1 || spec.Version > SpecVersion {
+		return nil, fmt.Errorf("unsupported specification version %d", spec.Version)
+	}
+	if spec.Command == nil {
+		return nil, fmt.Errorf("invalid specification: missing command")
+	}
+
+	return spec.Command.build()
+}
+
+// Find returns the command found by following the space separated
+// subcommand names or aliases in path from the command, e.g. "foo bar",
+// or nil if there is no such command. An empty path returns the command.
+func (c *Command) Find(path string) *Command {
+	cmd := c
+	for _, name := range strings.Fields(path) {
+		if cmd = cmd.Lookup(name); cmd == nil {
+			return nil
+		}
+	}
+	return cmd
+}
+
+// Find returns the command found by following the space separated
+// command names in path. See Command.Find.
+func Find(path string) *Command {
+	return command.Find(path)
+}
+
+// build creates the command and its subcommands described by the specification.
+func (s *CommandSpec) build() (*Command, error) {
+	// Keep the flags in the specified order.
+	cmd := NewCommand(s.Name, s.Usage, NewFlagSet("", flag.ContinueOnError))
+	cmd.flags.SortFlags = false
+	cmd.SetDescription(s.Description)
+	if len(s.Aliases) > 0 {
+		if err := cmd.SetAliases(s.Aliases...); err != nil {
+			return nil, fmt.Errorf("command '%s': %v", s.Name, err)
+		}
+	}
+	if s.Hidden {
+		cmd.MarkHidden()
+	}
+	if s.Deprecated {
+		cmd.MarkDeprecated()
+	}
+
+	for _, arg := range s.Args {
+		if err := cmd.addArg(&Arg{Name: arg.Name, Usage: arg.Usage, Optional: arg.Optional, Variadic: arg.Variadic}); err != nil {
+			return nil, fmt.Errorf("command '%s': %v", s.Name, err)
+		}
+	}
+
+	for _, f := range s.Flags {
+		flags := cmd.flags
+		if f.Persistent {
+			if cmd.persistent == nil {
+				cmd.persistent = NewFlagSet("", flag.ContinueOnError)
+				cmd.persistent.SortFlags = false
+			}
+			flags = cmd.persistent
+		}
+		if err := f.define(flags); err != nil {
+			return nil, fmt.Errorf("command '%s': flag '%s': %v", s.Name, f.Name, err)
+		}
+	}
+
+	for _, subSpec := range s.Commands {
+		subCmd, err := subSpec.build()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.AddCommand(subCmd); err != nil {
+			return nil, fmt.Errorf("command '%s': %v", s.Name, err)
+		}
+	}
+
+	return cmd, nil
+}
+
+// define defines the flag described by the specification in flags.
+func (s *FlagSpec) define(flags *flag.FlagSet) error {
+	if len(s.Name) == 0 {
+		return fmt.Errorf("missing name")
+	}
+	if flags.Lookup(s.Name) != nil {
+		return fmt.Errorf("flag already exists")
+	}
+	if len(s.Shorthand) > 1 {
+		return fmt.Errorf("invalid shorthand %q", s.Shorthand)
+	}
+
+	// Slice and map defaults are enclosed in brackets,
+	// and unset IP defaults are written as "<nil>".
+	def := s.Default
+	if def == "<nil>" {
+		def = ""
+	}
+	if strings.HasSuffix(s.Type, "Slice") || strings.HasSuffix(s.Type, "Array") || strings.HasPrefix(s.Type, "stringTo") {
+		def = strings.TrimSuffix(strings.TrimPrefix(def, "["), "]")
+	}
+
+	// Define the types without a distinct Go type directly.
+	switch s.Type {
+	case "count":
+		if len(def) > 0 && def != "0" {
+			return fmt.Errorf("invalid default value %q", s.Default)
+		}
+		flags.CountP(s.Name, s.Shorthand, s.Usage)
+	case "stringArray":
+		var values []string
+		if len(def) > 0 {
+			var err error
+			if values, err = csv.NewReader(strings.NewReader(def)).Read(); err != nil {
+				return fmt.Errorf("invalid default value %q: %v", s.Default, err)
+			}
+		}
+		flags.StringArrayP(s.Name, s.Shorthand, values, s.Usage)
+	case "bytesHex":
+		value, err := hex.DecodeString(def)
+		if err != nil {
+			return fmt.Errorf("invalid default value %q: %v", s.Default, err)
+		}
+		flags.BytesHexP(s.Name, s.Shorthand, value, s.Usage)
+	case "bytesBase64":
+		value, err := base64.StdEncoding.DecodeString(def)
+		if err != nil {
+			return fmt.Errorf("invalid default value %q: %v", s.Default, err)
+		}
+		flags.BytesBase64P(s.Name, s.Shorthand, value, s.Usage)
+	default:
+		ptr := specFlagVar(s.Type)
+		if ptr == nil {
+			return fmt.Errorf("unsupported type %q", s.Type)
+		}
+
+		// Write the default value to the variable, so that it is
+		// used as the default when defining the flag.
+		if len(def) > 0 {
+			scratch := NewFlagSet("", flag.ContinueOnError)
+			if err := defineFlagVar(scratch, ptr, s.Name, "", ""); err != nil {
+				return err
+			}
+			if err := scratch.Set(s.Name, def); err != nil {
+				return fmt.Errorf("invalid default value %q: %v", s.Default, err)
+			}
+		}
+		if err := defineFlagVar(flags, ptr, s.Name, s.Shorthand, s.Usage); err != nil {
+			return err
+		}
+	}
+
+	f := flags.Lookup(s.Name)
+	f.Hidden = s.Hidden
+	if len(s.Deprecated) > 0 {
+		if err := flags.MarkDeprecated(s.Name, s.Deprecated); err != nil {
+			return err
+		}
+	}
+	for key, values := range s.Annotations {
+		if err := flags.SetAnnotation(s.Name, key, values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// specFlagVar returns a pointer to a new variable for the pflag type name,
+// or nil if the type is not supported by defineFlagVar.
+func specFlagVar(typeName string) any {
+	switch typeName {
+	case "bool":
+		return new(bool)
+	case "string":
+		return new(string)
+	case "int":
+		return new(int)
+	case "int8":
+		return new(int8)
+	case "int16":
+		return new(int16)
+	case "int32":
+		return new(int32)
+	case "int64":
+		return new(int64)
+	case "uint":
+		return new(uint)
+	case "uint8":
+		return new(uint8)
+	case "uint16":
+		return new(uint16)
+	case "uint32":
+		return new(uint32)
+	case "uint64":
+		return new(uint64)
+	case "float32":
+		return new(float32)
+	case "float64":
+		return new(float64)
+	case "duration":
+		return new(time.Duration)
+	case "ip":
+		return new(net.IP)
+	case "ipNet":
+		return new(net.IPNet)
+	case "ipMask":
+		return new(net.IPMask)
+	case "stringSlice":
+		return new([]string)
+	case "boolSlice":
+		return new([]bool)
+	case "intSlice":
+		return new([]int)
+	case "int32Slice":
+		return new([]int32)
+	case "int64Slice":
+		return new([]int64)
+	case "uintSlice":
+		return new([]uint)
+	case "float32Slice":
+		return new([]float32)
+	case "float64Slice":
+		return new([]float64)
+	case "durationSlice":
+		return new([]time.Duration)
+	case "ipSlice":
+		return new([]net.IP)
+	case "stringToString":
+		return new(map[string]string)
+	case "stringToInt":
+		return new(map[string]int)
+	case "stringToInt64":
+		return new(map[string]int64)
+	}
+	return nil
+}
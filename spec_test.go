@@ -0,0 +1,170 @@
+package cflag
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalSpec(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	command.flags = ctx.flags
+
+	// Define persistent, required, hidden and deprecated flags.
+	flagsGlobal := NewFlagSet("", flag.ContinueOnError)
+	flagsGlobal.BoolP("verbose", "V", false, "Verbose output.")
+	SetPersistentFlags(flagsGlobal)
+	a.NoError(ctx.cmdFoo.MarkFlagRequired("test1"))
+	ctx.flagsTypes.String("secret", "", "Secret flag.")
+	a.NoError(ctx.flagsTypes.MarkHidden("secret"))
+	ctx.flagsTypes.String("old", "x", "Old flag.")
+	a.NoError(ctx.flagsTypes.MarkDeprecated("old", "use --str instead"))
+	a.NoError(ctx.cmdWorld.SetAliases("w"))
+	ctx.cmdWorld.MarkDeprecated()
+	a.NoError(ctx.cmdFooBar.AddOptionalArg("file", "Input file."))
+
+	// Marshal and decode the specification.
+	data, err := MarshalSpec()
+	a.NoError(err)
+	t.Log(string(data))
+	var spec Spec
+	a.NoError(json.Unmarshal(data, &spec))
+
+	// Check the command tree.
+	a.Equal(SpecVersion, spec.Version)
+	root := spec.Command
+	a.Equal("cflag test application.", root.Description)
+	a.Equal([]*FlagSpec{
+		{Name: "test0", Type: "int", Default: "0", Usage: "Test 0."},
+		{Name: "version", Shorthand: "v", Type: "bool", Default: "false", Usage: "Display the application version."},
+		{Name: "verbose", Shorthand: "V", Type: "bool", Default: "false", Usage: "Verbose output.", Persistent: true},
+	}, root.Flags)
+	a.Len(root.Commands, 3)
+
+	foo := root.Commands[0]
+	a.Equal("foo", foo.Name)
+	a.Equal("Foo command.", foo.Usage)
+	a.Equal("Foo command description.", foo.Description)
+	a.Equal([]*FlagSpec{
+		{Name: "test1", Type: "int", Default: "1", Usage: "Test 1.", Annotations: map[string][]string{requiredAnnotation: {"true"}}},
+	}, foo.Flags)
+	a.Equal([]*ArgSpec{{Name: "file", Usage: "Input file.", Optional: true}}, foo.Commands[0].Args)
+
+	world := root.Commands[1]
+	a.Equal([]string{"w"}, world.Aliases)
+	a.True(world.Hidden)
+	a.True(world.Deprecated)
+
+	types := root.Commands[2]
+	a.Len(types.Flags, 5)
+	a.True(types.Flags[3].Hidden)
+	a.Equal("use --str instead", types.Flags[4].Deprecated)
+}
+
+func TestLoadSpec(t *testing.T) {
+	a := assert.New(t)
+
+	// Load a YAML specification.
+	root, err := LoadSpec(strings.NewReader(`
+version: 1
+command:
+  name: ""
+  usage: App command.
+  flags:
+    - {name: verbose, shorthand: V, type: bool, default: "false", usage: Verbose output., persistent: true}
+  commands:
+    - name: serve
+      usage: Serve command.
+      description: Start the server.
+      aliases: [s]
+      args:
+        - {name: dir, usage: Root directory., optional: true}
+      flags:
+        - {name: port, shorthand: p, type: int, default: "8080", usage: Port.}
+        - {name: tags, type: stringSlice, default: "[a,b]", usage: Tags.}
+        - {name: env, type: stringToString, default: "[k=v]", usage: Environment.}
+        - {name: headers, type: stringArray, default: "[x,y]", usage: Headers.}
+        - {name: key, type: bytesHex, default: "CAFE", usage: Key.}
+        - {name: verbosity, shorthand: v, type: count, usage: Verbosity.}
+        - {name: timeout, type: duration, default: 5s, usage: Timeout., hidden: true}
+        - {name: bind, type: ip, default: "<nil>", usage: Address., deprecated: use --listen}
+      commands:
+        - {name: old, usage: Old command., deprecated: true, hidden: true}
+`))
+	a.NoError(err)
+
+	// Check the command tree.
+	serve := root.Find("serve")
+	a.NotNil(serve)
+	a.Equal(serve, root.Find("s"))
+	a.Nil(root.Find("serve other"))
+	a.Equal("Start the server.", serve.GetDescription())
+	a.Contains(serve.Synopsis(), "[dir]")
+	old := root.Find("serve old")
+	a.True(old.IsDeprecated())
+	a.True(serve.GetFlags().Lookup("timeout").Hidden)
+	a.Equal("use --listen", serve.GetFlags().Lookup("bind").Deprecated)
+
+	// Bind a callback and parse arguments.
+	SetNoExit()
+	var called bool
+	serve.SetCallback(func(command *Command, flags *flag.FlagSet) error {
+		called = true
+		port, _ := flags.GetInt("port")
+		tags, _ := flags.GetStringSlice("tags")
+		env, _ := flags.GetStringToString("env")
+		headers, _ := flags.GetStringArray("headers")
+		key, _ := flags.GetBytesHex("key")
+		v, _ := flags.GetCount("verbosity")
+		verbose, _ := flags.GetBool("verbose")
+		a.Equal(9090, port)
+		a.Equal([]string{"c"}, tags)
+		a.Equal(map[string]string{"k": "v"}, env)
+		a.Equal([]string{"x", "y"}, headers)
+		a.Equal([]byte{0xca, 0xfe}, key)
+		a.Equal(2, v)
+		a.True(verbose)
+		return nil
+	})
+	a.NoError(root.Parse([]string{"app", "-V", "serve", "-p", "9090", "--tags", "c", "-vv"}))
+	a.True(called)
+}
+
+func TestLoadSpecRoundTrip(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+	command.flags = ctx.flags
+	a.NoError(ctx.cmdFoo.MarkFlagRequired("test1"))
+
+	// Marshal, load and marshal the specification again.
+	data, err := MarshalSpec()
+	a.NoError(err)
+	root, err := LoadSpec(bytes.NewReader(data))
+	a.NoError(err)
+	data2, err := root.MarshalSpec()
+	a.NoError(err)
+	a.JSONEq(string(data), string(data2))
+}
+
+func TestLoadSpecInvalid(t *testing.T) {
+	a := assert.New(t)
+
+	tests := []string{
+		`{"version": 2, "command": {"name": ""}}`,
+		`{"version": 1}`,
+		`{"version": 1, "command": {"name": "", "unknown": true}}`,
+		`{"version": 1, "command": {"name": "", "flags": [{"name": "a", "type": "complex"}]}}`,
+		`{"version": 1, "command": {"name": "", "flags": [{"name": "a", "type": "int", "default": "x"}]}}`,
+		`{"version": 1, "command": {"name": "", "commands": [{"name": "a"}, {"name": "a"}]}}`,
+	}
+	for _, test := range tests {
+		_, err := LoadSpec(strings.NewReader(test))
+		t.Log(err)
+		a.Error(err, test)
+	}
+}
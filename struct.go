@@ -0,0 +1,244 @@
+package cflag
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// The reflected flag.Value interface type.
+var flagValueType = reflect.TypeOf((*flag.Value)(nil)).Elem()
+
+// FromStruct creates a top-level command from the struct pointed to by v.
+// Exported fields tagged with `cflag:"name"` are defined as flags bound to
+// the field, so that Parse fills the struct. Fields tagged with `cmd:"name"`
+// must hold a struct or a pointer to a struct and are defined as subcommands.
+//
+// The following tags are supported for flags:
+//
+//	cflag:"name"       flag name, "-" skips the field
+//	short:"n"          flag shorthand
+//	usage:"..."        flag usage
+//	default:"..."      default value, parsed like a command line value
+//	env:"VAR"          environment variable, see Command.BindFlagEnv
+//	persistent:"true"  define a persistent flag, see Command.SetPersistentFlags
+//	hidden:"true"      hide the flag in help and usage messages
+//
+// The current field values are used as defaults unless a default tag is set.
+// Supported field types are all types with a pflag definition function,
+// e.g. bool, int, string, time.Duration, []string, map[string]string and net.IP,
+// and types implementing flag.Value with a pointer receiver.
+//
+// The following tags are supported for subcommands:
+//
+//	cmd:"name"         command name
+//	usage:"..."        command usage
+//	description:"..."  command description, see Command.SetDescription
+//	aliases:"a,b"      comma separated command aliases, see Command.SetAliases
+//	hidden:"true"      hide the command in help and usage messages
+func FromStruct(v any) (*Command, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+
+	cmd := NewCommand("", "", nil)
+	if err := cmd.defineStruct(value.Elem()); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// defineStruct defines the flags and subcommands
+// described by the tagged fields of the struct v.
+func (c *Command) defineStruct(v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		// Define subcommand.
+		if name, ok := field.Tag.Lookup("cmd"); ok {
+			if err := c.defineStructCommand(name, field, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Define flag.
+		name, ok := field.Tag.Lookup("cflag")
+		if !ok || name == "-" {
+			continue
+		}
+		if err := c.defineStructFlag(name, field, v.Field(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// defineStructCommand adds the subcommand name described by the struct field.
+func (c *Command) defineStructCommand(name string, field reflect.StructField, value reflect.Value) error {
+	// Allocate nil struct pointers.
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			value.Set(reflect.New(field.Type.Elem()))
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("field '%s': command '%s' must be a struct", field.Name, name)
+	}
+
+	cmd := NewCommand(name, field.Tag.Get("usage"), nil)
+	cmd.SetDescription(field.Tag.Get("description"))
+	if aliases := field.Tag.Get("aliases"); len(aliases) > 0 {
+		if err := cmd.SetAliases(strings.Split(aliases, ",")...); err != nil {
+			return fmt.Errorf("field '%s': %v", field.Name, err)
+		}
+	}
+	if field.Tag.Get("hidden") == "true" {
+		cmd.MarkHidden()
+	}
+	if err := cmd.defineStruct(value); err != nil {
+		return err
+	}
+
+	return c.AddCommand(cmd)
+}
+
+// defineStructFlag defines the flag name bound to the struct field.
+func (c *Command) defineStructFlag(name string, field reflect.StructField, value reflect.Value) error {
+	// Use pointers to flag.Value implementations directly
+	// and allocate them if necessary.
+	ptr := value.Addr().Interface()
+	if value.Kind() == reflect.Pointer && field.Type.Implements(flagValueType) {
+		if value.IsNil() {
+			value.Set(reflect.New(field.Type.Elem()))
+		}
+		ptr = value.Interface()
+	}
+
+	// Select the flag set.
+	var flags *flag.FlagSet
+	if field.Tag.Get("persistent") == "true" {
+		if c.persistent == nil {
+			c.persistent = NewFlagSet("", flag.ContinueOnError)
+		}
+		flags = c.persistent
+	} else {
+		if c.flags == nil {
+			c.flags = NewFlagSet("", flag.ContinueOnError)
+		}
+		flags = c.flags
+	}
+
+	// Write the default value to the field, so that it is
+	// used as the default when defining the flag.
+	if def, ok := field.Tag.Lookup("default"); ok {
+		scratch := NewFlagSet("", flag.ContinueOnError)
+		if err := defineFlagVar(scratch, ptr, name, "", ""); err != nil {
+			return fmt.Errorf("field '%s': %v", field.Name, err)
+		}
+		if err := scratch.Set(name, def); err != nil {
+			return fmt.Errorf("field '%s': invalid default value %q: %v", field.Name, def, err)
+		}
+	}
+
+	if flags.Lookup(name) != nil {
+		return fmt.Errorf("field '%s': flag '%s' already exists", field.Name, name)
+	}
+	if err := defineFlagVar(flags, ptr, name, field.Tag.Get("short"), field.Tag.Get("usage")); err != nil {
+		return fmt.Errorf("field '%s': %v", field.Name, err)
+	}
+	if field.Tag.Get("hidden") == "true" {
+		flags.Lookup(name).Hidden = true
+	}
+	if env := field.Tag.Get("env"); len(env) > 0 {
+		return c.BindFlagEnv(name, env)
+	}
+
+	return nil
+}
+
+// defineFlagVar defines a flag bound to the variable ptr points to,
+// using the current value of the variable as the default value.
+func defineFlagVar(flags *flag.FlagSet, ptr any, name, shorthand, usage string) error {
+	switch p := ptr.(type) {
+	case flag.Value:
+		flags.VarP(p, name, shorthand, usage)
+	case *bool:
+		flags.BoolVarP(p, name, shorthand, *p, usage)
+	case *string:
+		flags.StringVarP(p, name, shorthand, *p, usage)
+	case *int:
+		flags.IntVarP(p, name, shorthand, *p, usage)
+	case *int8:
+		flags.Int8VarP(p, name, shorthand, *p, usage)
+	case *int16:
+		flags.Int16VarP(p, name, shorthand, *p, usage)
+	case *int32:
+		flags.Int32VarP(p, name, shorthand, *p, usage)
+	case *int64:
+		flags.Int64VarP(p, name, shorthand, *p, usage)
+	case *uint:
+		flags.UintVarP(p, name, shorthand, *p, usage)
+	case *uint8:
+		flags.Uint8VarP(p, name, shorthand, *p, usage)
+	case *uint16:
+		flags.Uint16VarP(p, name, shorthand, *p, usage)
+	case *uint32:
+		flags.Uint32VarP(p, name, shorthand, *p, usage)
+	case *uint64:
+		flags.Uint64VarP(p, name, shorthand, *p, usage)
+	case *float32:
+		flags.Float32VarP(p, name, shorthand, *p, usage)
+	case *float64:
+		flags.Float64VarP(p, name, shorthand, *p, usage)
+	case *time.Duration:
+		flags.DurationVarP(p, name, shorthand, *p, usage)
+	case *net.IP:
+		flags.IPVarP(p, name, shorthand, *p, usage)
+	case *net.IPNet:
+		flags.IPNetVarP(p, name, shorthand, *p, usage)
+	case *net.IPMask:
+		flags.IPMaskVarP(p, name, shorthand, *p, usage)
+	case *[]string:
+		flags.StringSliceVarP(p, name, shorthand, *p, usage)
+	case *[]bool:
+		flags.BoolSliceVarP(p, name, shorthand, *p, usage)
+	case *[]int:
+		flags.IntSliceVarP(p, name, shorthand, *p, usage)
+	case *[]int32:
+		flags.Int32SliceVarP(p, name, shorthand, *p, usage)
+	case *[]int64:
+		flags.Int64SliceVarP(p, name, shorthand, *p, usage)
+	case *[]uint:
+		flags.UintSliceVarP(p, name, shorthand, *p, usage)
+	case *[]float32:
+		flags.Float32SliceVarP(p, name, shorthand, *p, usage)
+	case *[]float64:
+		flags.Float64SliceVarP(p, name, shorthand, *p, usage)
+	case *[]time.Duration:
+		flags.DurationSliceVarP(p, name, shorthand, *p, usage)
+	case *[]net.IP:
+		flags.IPSliceVarP(p, name, shorthand, *p, usage)
+	case *map[string]string:
+		flags.StringToStringVarP(p, name, shorthand, *p, usage)
+	case *map[string]int:
+		flags.StringToIntVarP(p, name, shorthand, *p, usage)
+	case *map[string]int64:
+		flags.StringToInt64VarP(p, name, shorthand, *p, usage)
+	default:
+		return fmt.Errorf("unsupported type %T", ptr)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package cflag
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// upperValue is a custom flag.Value storing upper case strings.
+type upperValue string
+
+func (v *upperValue) String() string {
+	return string(*v)
+}
+
+func (v *upperValue) Set(s string) error {
+	*v = upperValue(strings.ToUpper(s))
+	return nil
+}
+
+func (v *upperValue) Type() string {
+	return "upper"
+}
+
+type testStructConfig struct {
+	Test0   int    `cflag:"test0" usage:"Test 0."`
+	Version bool   `cflag:"version" short:"v" usage:"Display the application version."`
+	Verbose bool   `cflag:"verbose" short:"V" usage:"Verbose output." persistent:"true"`
+	Ignored string `cflag:"-"`
+	Foo     struct {
+		Test1 int               `cflag:"test1" short:"t" usage:"Test 1." default:"1" env:"TEST1"`
+		Names []string          `cflag:"names" usage:"Names." default:"a,b"`
+		Wait  time.Duration     `cflag:"wait" usage:"Wait time." default:"1s"`
+		Label map[string]string `cflag:"label" usage:"Labels."`
+		Name  upperValue        `cflag:"name" usage:"Upper case name."`
+		Bar   *struct {
+			Test2 int `cflag:"test2" usage:"Test 2." default:"2"`
+		} `cmd:"bar" usage:"Bar command."`
+	} `cmd:"foo" usage:"Foo command." aliases:"f"`
+}
+
+func TestFromStruct(t *testing.T) {
+	a := assert.New(t)
+	Reset()
+
+	// Create command tree from struct.
+	cfg := testStructConfig{Test0: 5}
+	cmd, err := FromStruct(&cfg)
+	a.NoError(err)
+	a.Equal("Foo command.", cmd.Lookup("foo").GetUsage())
+	a.NotNil(cmd.Lookup("f").Lookup("bar"))
+	a.Equal(1, cfg.Foo.Test1)
+	a.Equal(2, cfg.Foo.Bar.Test2)
+	t.Setenv("TEST1", "11")
+
+	// Run cflag parser.
+	args := append(slices.Clone(os.Args[:1]), "--test0", "10", "f", "--names", "c",
+		"--wait", "2m", "--label", "k=v", "--name", "foo", "bar", "--test2", "12", "-V")
+	a.Nil(cmd.Parse(args))
+
+	// Check struct values.
+	a.Equal(10, cfg.Test0)
+	a.False(cfg.Version)
+	a.True(cfg.Verbose)
+	a.Equal(11, cfg.Foo.Test1)
+	a.Equal([]string{"c"}, cfg.Foo.Names)
+	a.Equal(2*time.Minute, cfg.Foo.Wait)
+	a.Equal(map[string]string{"k": "v"}, cfg.Foo.Label)
+	a.Equal(upperValue("FOO"), cfg.Foo.Name)
+	a.Equal(12, cfg.Foo.Bar.Test2)
+
+	// Check help output.
+	output := cmd.Lookup("foo").CommandUsage()
+	t.Log(output)
+	a.Contains(output, "-t, --test1 int")
+	a.Contains(output, "(default 1)")
+	a.Contains(output, "[$TEST1]")
+}
+
+func TestFromStructInvalid(t *testing.T) {
+	a := assert.New(t)
+
+	// Check invalid parameters and field types.
+	_, err := FromStruct(testStructConfig{})
+	a.Error(err)
+	_, err = FromStruct(&struct {
+		Test chan int `cflag:"test"`
+	}{})
+	a.Error(err)
+	_, err = FromStruct(&struct {
+		Test int `cflag:"test" default:"abc"`
+	}{})
+	a.Error(err)
+	_, err = FromStruct(&struct {
+		Foo int `cmd:"foo"`
+	}{})
+	a.Error(err)
+}
@@ -0,0 +1,223 @@
+package cflag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// An enumError is returned by the validators of OneOf for rejected values.
+type enumError struct {
+	values []string
+}
+
+func (e *enumError) Error() string {
+	return fmt.Sprintf("must be one of %s", strings.Join(e.values, ", "))
+}
+
+// OneOf returns a validator accepting the values only.
+// Use SetFlagEnum to also list the values in the help output
+// and complete them.
+func OneOf(values ...string) func(string) error {
+	return func(value string) error {
+		if slices.Contains(values, value) {
+			return nil
+		}
+		return &enumError{values: values}
+	}
+}
+
+// IntRange returns a validator accepting integers between min and max, inclusive.
+func IntRange(min, max int) func(string) error {
+	return func(value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		if i < min || i > max {
+			return fmt.Errorf("must be between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
+// MatchesRegexp returns a validator accepting values matching the regular expression re.
+func MatchesRegexp(re *regexp.Regexp) func(string) error {
+	return func(value string) error {
+		if !re.MatchString(value) {
+			return fmt.Errorf("must match %q", re.String())
+		}
+		return nil
+	}
+}
+
+// ExistingFile returns a validator accepting paths of existing regular files.
+func ExistingFile() func(string) error {
+	return func(value string) error {
+		info, err := os.Stat(value)
+		if err != nil {
+			return fmt.Errorf("file does not exist")
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("not a regular file")
+		}
+		return nil
+	}
+}
+
+// WritableDir returns a validator accepting paths of existing directories
+// in which files can be created.
+func WritableDir() func(string) error {
+	return func(value string) error {
+		info, err := os.Stat(value)
+		if err != nil {
+			return fmt.Errorf("directory does not exist")
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("not a directory")
+		}
+
+		// Probe write access by creating a temporary file.
+		f, err := os.CreateTemp(value, ".cflag-*")
+		if err != nil {
+			return fmt.Errorf("directory is not writable")
+		}
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil
+	}
+}
+
+// ValidateFlag adds validators for the flag name. When the command is active
+// and the flag is set on the command line, from the environment or from a
+// configuration file, Parse runs the validators after parsing the whole chain
+// and before executing the callbacks. Each element of slice values is
+// validated separately. Parse returns a *FlagValidationError for each
+// invalid value. The flag must be defined for the command or inherited
+// from a parent command.
+func (c *Command) ValidateFlag(name string, validators ...func(string) error) error {
+	if len(validators) == 0 {
+		return fmt.Errorf("invalid parameters")
+	}
+	if c.lookupFlag(name) == nil {
+		return fmt.Errorf("flag '%s' does not exist", name)
+	}
+
+	if c.validators == nil {
+		c.validators = make(map[string][]func(string) error)
+	}
+	c.validators[name] = append(c.validators[name], validators...)
+
+	return nil
+}
+
+// ValidateFlag adds validators for the top-level flag name.
+// See Command.ValidateFlag.
+func ValidateFlag(name string, validators ...func(string) error) error {
+	return command.ValidateFlag(name, validators...)
+}
+
+// SetFlagEnum restricts the flag name to the values. The values are
+// validated like a OneOf validator added with ValidateFlag, listed in the
+// help output and completed by the shell completion scripts. The flag must
+// be defined for the command or inherited from a parent command.
+func (c *Command) SetFlagEnum(name string, values ...string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("invalid parameters")
+	}
+	if err := c.ValidateFlag(name, OneOf(values...)); err != nil {
+		return err
+	}
+
+	if c.enums == nil {
+		c.enums = make(map[string][]string)
+	}
+	c.enums[name] = values
+	return nil
+}
+
+// SetFlagEnum restricts the top-level flag name to the values.
+// See Command.SetFlagEnum.
+func SetFlagEnum(name string, values ...string) error {
+	return command.SetFlagEnum(name, values...)
+}
+
+// validateFlags runs the validators of all commands in cmdChain
+// and returns the joined validation errors.
+func validateFlags(cmdChain []*Command) error {
+	var errs []error
+
+	for _, cmd := range cmdChain {
+		// Sort names for a stable error order.
+		names := make([]string, 0, len(cmd.validators))
+		for name := range cmd.validators {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			f := cmd.lookupFlag(name)
+			if f == nil || cmd.Source(name) == SourceDefault {
+				continue
+			}
+			for _, value := range flagValues(f) {
+				for _, validator := range cmd.validators[name] {
+					if err := validator(value); err != nil {
+						errs = append(errs, &FlagValidationError{Command: cmd, Flag: name, Value: value, Err: err})
+						break
+					}
+				}
+			}
+		}
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// flagValues returns the elements of slice values
+// or the single value of other flags.
+func flagValues(f *flag.Flag) []string {
+	if slice, ok := f.Value.(flag.SliceValue); ok {
+		return slice.GetSlice()
+	}
+	return []string{f.Value.String()}
+}
+
+// enumValues returns the values set with SetFlagEnum for the flag name
+// by the command or one of its parent commands.
+func (c *Command) enumValues(name string) []string {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if values, ok := cmd.enums[name]; ok {
+			return values
+		}
+	}
+	return nil
+}
+
+// enumCompletionFunc returns a completion function
+// completing the values accepted by the flag name.
+func (c *Command) enumCompletionFunc(name string) CompletionFunc {
+	values := c.enumValues(name)
+	if len(values) == 0 {
+		return nil
+	}
+	return func(command *Command, args []string, toComplete string) ([]Completion, CompletionDirective) {
+		var completions []Completion
+		for _, value := range values {
+			if strings.HasPrefix(value, toComplete) {
+				completions = append(completions, Completion{Value: value})
+			}
+		}
+		return completions, CompletionNoFileComp
+	}
+}
@@ -0,0 +1,105 @@
+package cflag
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildValidateTestContext returns a test context with flag validators.
+func buildValidateTestContext(a *assert.Assertions) *testContext {
+	ctx := buildTestContext()
+	a.NoError(ctx.cmdFoo.ValidateFlag("test1", IntRange(0, 100)))
+	a.NoError(ctx.cmdTypes.SetFlagEnum("str", "red", "green", "blue"))
+	a.NoError(ctx.cmdTypes.ValidateFlag("int", func(value string) error {
+		if value == "13" {
+			return errors.New("unlucky number")
+		}
+		return nil
+	}))
+	a.Error(ctx.cmdTypes.ValidateFlag("other", OneOf("a")))
+	a.Error(ctx.cmdTypes.ValidateFlag("str"))
+	a.Error(ctx.cmdTypes.SetFlagEnum("other", "a"))
+	a.Error(ctx.cmdTypes.SetFlagEnum("str"))
+	return ctx
+}
+
+func TestValidateFlag(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildValidateTestContext(a)
+
+	// Check help output.
+	output := ctx.cmdTypes.CommandUsage()
+	t.Log(output)
+	a.Contains(output, "String flag. (one of: red, green, blue)")
+	a.Equal(1, strings.Count(output, "one of"))
+
+	// Plain OneOf validators are not listed.
+	a.NoError(ctx.cmdTypes.ValidateFlag("int", OneOf("1", "2")))
+	a.Equal(1, strings.Count(ctx.cmdTypes.CommandUsage(), "one of"))
+
+	// Check completion of enum values.
+	completions, directive := ctx.cmdTypes.Complete([]string{"app", "types", "--str", "g"})
+	a.Equal([]Completion{{Value: "green"}}, completions)
+	a.Equal(CompletionNoFileComp, directive)
+
+	// Valid values.
+	a.Nil(Parse(append(ctx.arguments, "foo", "--test1", "50"), ctx.flags))
+	ctx = buildValidateTestContext(a)
+	a.Nil(Parse(append(ctx.arguments, "types", "-s", "green", "-i", "12"), ctx.flags))
+
+	// Invalid values.
+	ctx = buildValidateTestContext(a)
+	err := Parse(append(ctx.arguments, "foo", "--test1", "500"), ctx.flags)
+	t.Log(err)
+	var validationErr *FlagValidationError
+	if a.ErrorAs(err, &validationErr) {
+		a.Equal(ctx.cmdFoo, validationErr.Command)
+		a.Equal("test1", validationErr.Flag)
+		a.Equal("500", validationErr.Value)
+	}
+	ctx = buildValidateTestContext(a)
+	err = Parse(append(ctx.arguments, "types", "-s", "pink", "-i", "13"), ctx.flags)
+	t.Log(err)
+	a.Contains(err.Error(), `types": invalid value "pink" for flag "--str": must be one of red, green, blue`)
+	a.Contains(err.Error(), `invalid value "13" for flag "--int": unlucky number`)
+}
+
+func TestValidators(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	a.NoError(os.WriteFile(file, nil, 0o644))
+
+	a.NoError(OneOf("a", "b")("a"))
+	a.Error(OneOf("a", "b")("c"))
+	a.NoError(IntRange(1, 3)("3"))
+	a.Error(IntRange(1, 3)("4"))
+	a.Error(IntRange(1, 3)("x"))
+	a.NoError(MatchesRegexp(regexp.MustCompile(`^v\d+$`))("v1"))
+	a.Error(MatchesRegexp(regexp.MustCompile(`^v\d+$`))("1"))
+	a.NoError(ExistingFile()(file))
+	a.Error(ExistingFile()(dir))
+	a.Error(ExistingFile()(filepath.Join(dir, "missing")))
+	a.NoError(WritableDir()(dir))
+	a.Error(WritableDir()(file))
+}
+
+func TestValidateSlice(t *testing.T) {
+	a := assert.New(t)
+	ctx := buildTestContext()
+
+	// Each element of a slice value is validated.
+	flags := NewFlagSet("", flag.ContinueOnError)
+	flags.StringSlice("color", nil, "Colors.")
+	cmd, _ := Cmd("paint", "Paint command.", flags)
+	a.NoError(cmd.ValidateFlag("color", OneOf("red", "green")))
+	err := Parse(append(ctx.arguments, "paint", "--color", "red,pink"), ctx.flags)
+	a.ErrorContains(err, `invalid value "pink"`)
+}